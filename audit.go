@@ -0,0 +1,228 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/verdverm/frisby"
+)
+
+// checks performed by security headers audit, they are named by inspected
+// header
+const (
+	AuditContentTypeOptions = "X-Content-Type-Options"
+	AuditCacheControl       = "Cache-Control"
+	AuditHSTS               = "Strict-Transport-Security"
+	AuditServerBanner       = "Server"
+)
+
+// severities of audit findings, only findings with SeverityError are
+// counted as test errors
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+	SeverityIgnore  = "ignore"
+)
+
+// severity used for checks without severity set by test
+const defaultAuditSeverity = SeverityWarning
+
+// auditSecurityHeaders enables security headers audit of all responses
+var auditSecurityHeaders = flag.Bool("audit", false,
+	"audit security headers of all responses")
+
+// all checks performed by security headers audit
+var auditChecks = []string{
+	AuditContentTypeOptions,
+	AuditCacheControl,
+	AuditHSTS,
+	AuditServerBanner,
+}
+
+// all known severities of audit findings
+var auditSeverities = []string{
+	SeverityError,
+	SeverityWarning,
+	SeverityInfo,
+	SeverityIgnore,
+}
+
+// versionBannerPattern matches version in Server header, like nginx/1.20.1
+// or Apache 2.4
+var versionBannerPattern = regexp.MustCompile(`/\s*v?\d|\d+\.\d+`)
+
+// auditProblem represents problem found by one audit check
+type auditProblem struct {
+	Check   string
+	Problem string
+}
+
+// auditFinding represents problem found by audit in response to one request
+type auditFinding struct {
+	Test     string
+	Check    string
+	Severity string
+	Problem  string
+}
+
+// findings collected by security headers audit
+var auditFindings []auditFinding
+
+// isAuthenticated checks if request contains credentials
+func isAuthenticated(e *exchange) bool {
+	header := e.Request.Header
+	return header.Get(authHeaderName) != "" || header.Get("Authorization") != "" || header.Get("Cookie") != ""
+}
+
+// securityHeaderProblems inspects security relevant headers of recorded
+// response
+func securityHeaderProblems(e *exchange) []auditProblem {
+	var problems []auditProblem
+	header := e.Response.Header
+
+	add := func(check string, format string, args ...interface{}) {
+		problems = append(problems, auditProblem{check, fmt.Sprintf(format, args...)})
+	}
+
+	if value := header.Get(AuditContentTypeOptions); !strings.EqualFold(strings.TrimSpace(value), "nosniff") {
+		add(AuditContentTypeOptions, "Expected Header %q to be %q, but got %q", AuditContentTypeOptions, "nosniff", value)
+	}
+
+	if isAuthenticated(e) {
+		value := strings.ToLower(header.Get(AuditCacheControl))
+		if !strings.Contains(value, "no-store") && !strings.Contains(value, "private") {
+			add(AuditCacheControl, "Response to authenticated request can be cached, header %q is %q",
+				AuditCacheControl, header.Get(AuditCacheControl))
+		}
+	}
+
+	if e.Response.TLS != nil {
+		value := header.Get(AuditHSTS)
+		if maxAge, found := hstsMaxAge(value); !found || maxAge <= 0 {
+			add(AuditHSTS, "Expected Header %q with positive max-age, but got %q", AuditHSTS, value)
+		}
+	}
+
+	if value := header.Get(AuditServerBanner); versionBannerPattern.MatchString(value) {
+		add(AuditServerBanner, "Header %q reveals version of server %q", AuditServerBanner, value)
+	}
+
+	return problems
+}
+
+// hstsMaxAge reads max-age directive from Strict-Transport-Security header
+func hstsMaxAge(value string) (int, bool) {
+	for _, directive := range strings.Split(value, ";") {
+		parts := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "max-age") {
+			maxAge, err := strconv.Atoi(strings.Trim(parts[1], `"`))
+			return maxAge, err == nil
+		}
+	}
+	return 0, false
+}
+
+// auditSeverity returns severity of findings of given check
+func auditSeverity(check string, severities map[string]string) string {
+	for name, severity := range severities {
+		if strings.EqualFold(name, check) {
+			return strings.ToLower(severity)
+		}
+	}
+	return defaultAuditSeverity
+}
+
+// auditResponse inspects security headers of response when audit is
+// enabled. Findings with error severity are counted as test errors.
+func auditResponse(f *frisby.Frisby, e *exchange, severities map[string]string) {
+	if !*auditSecurityHeaders || e.Request == nil || e.Response == nil {
+		return
+	}
+
+	for _, problem := range securityHeaderProblems(e) {
+		severity := auditSeverity(problem.Check, severities)
+		if severity == SeverityIgnore {
+			continue
+		}
+		auditFindings = append(auditFindings, auditFinding{
+			Test:     f.Name,
+			Check:    problem.Check,
+			Severity: severity,
+			Problem:  problem.Problem,
+		})
+		if severity == SeverityError {
+			f.AddError("Security headers audit: " + problem.Problem)
+		}
+	}
+}
+
+// lintAuditSeverities checks that severities are set for known checks only
+func lintAuditSeverities(test *RestAPITest) []string {
+	var problems []string
+
+	for check, severity := range test.AuditSeverities {
+		if !containsFold(auditChecks, check) {
+			problems = append(problems, fmt.Sprintf("AuditSeverities contains unknown check %q", check))
+		}
+		if !containsFold(auditSeverities, severity) {
+			problems = append(problems, fmt.Sprintf("AuditSeverities contains unknown severity %q", severity))
+		}
+	}
+	return problems
+}
+
+// printAuditReport prints all findings of security headers audit grouped by
+// test
+func printAuditReport() {
+	fmt.Println("\nSecurity headers")
+	if len(auditFindings) == 0 {
+		fmt.Println("  No findings")
+		return
+	}
+
+	var tests []string
+	counts := make(map[string]int)
+	grouped := make(map[string][]auditFinding)
+	for _, finding := range auditFindings {
+		if _, found := grouped[finding.Test]; !found {
+			tests = append(tests, finding.Test)
+		}
+		grouped[finding.Test] = append(grouped[finding.Test], finding)
+		counts[finding.Severity]++
+	}
+
+	fmt.Printf("  FINDINGS  [%d]", len(auditFindings))
+	for _, severity := range auditSeverities {
+		if counts[severity] != 0 {
+			fmt.Printf("  %s: %d", severity, counts[severity])
+		}
+	}
+	fmt.Println()
+
+	for _, test := range tests {
+		fmt.Printf("      [%s]\n", test)
+		for _, finding := range grouped[test] {
+			fmt.Printf("        -  %s: %s\n", finding.Severity, finding.Problem)
+		}
+	}
+}
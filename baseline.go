@@ -0,0 +1,286 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/verdverm/frisby"
+)
+
+// durations shorter than this are too noisy to be compared with baseline
+const minComparedDuration = 10 * time.Millisecond
+
+// type of array items that are not of the same type
+const jsonTypeMixed = "mixed"
+
+// regression baseline settings read from command line
+var (
+	saveBaselineFile = flag.String("save-baseline", "",
+		"save status codes, header names, response shapes and durations of all tests into given file")
+	baselineFile = flag.String("baseline", "",
+		"compare all tests with baseline saved into given file and report regressions")
+	slowdownFactor = flag.Float64("slowdown", 3,
+		"report regression when test is slower than baseline by given factor")
+	failOnRegression = flag.Bool("fail-on-regression", false,
+		"count regressions found by comparison with baseline as test errors")
+)
+
+// Baseline represents results of all tests from one run that later runs are
+// compared with
+type Baseline struct {
+	Created time.Time
+	Target  string
+	Tests   map[string]BaselineEntry
+}
+
+// BaselineEntry represents result of one test. Shape maps paths to all
+// fields of JSON response to their type, array items are merged under *
+// wildcard.
+type BaselineEntry struct {
+	Status   int
+	Headers  []string
+	Shape    map[string]string `json:",omitempty"`
+	Duration time.Duration
+}
+
+// hop-by-hop and framing headers, they can change between responses without
+// any change of the service (for example Content-Length is replaced by
+// chunked transfer encoding for bigger bodies)
+var transportHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Transfer-Encoding",
+	"Te",
+	"Trailer",
+	"Upgrade",
+	contentLengthHeader,
+}
+
+// regression represents difference between test result and baseline
+type regression struct {
+	Test    string
+	Problem string
+}
+
+// regressions found by comparison with baseline
+var regressions []regression
+
+// responseShape adds paths and types of all fields from decoded JSON value
+// into shape
+func responseShape(shape map[string]string, path string, value interface{}) {
+	valueType := jsonType(value)
+	if known, found := shape[displayJSONPath(path)]; found && known != valueType {
+		valueType = jsonTypeMixed
+	}
+	shape[displayJSONPath(path)] = valueType
+
+	switch node := value.(type) {
+	case map[string]interface{}:
+		for key, item := range node {
+			responseShape(shape, joinJSONPath(path, key), item)
+		}
+	case []interface{}:
+		for _, item := range node {
+			responseShape(shape, joinJSONPath(path, jsonPathWildcard), item)
+		}
+	}
+}
+
+// newBaselineEntry constructs baseline entry from test result
+func newBaselineEntry(result testResult) (BaselineEntry, bool) {
+	e := result.Exchange
+	if e == nil || e.Response == nil {
+		return BaselineEntry{}, false
+	}
+
+	entry := BaselineEntry{
+		Status:   e.Response.StatusCode,
+		Duration: e.Timing.Total,
+	}
+	for name := range e.Response.Header {
+		entry.Headers = append(entry.Headers, name)
+	}
+	sort.Strings(entry.Headers)
+
+	var body interface{}
+	if json.Unmarshal(e.ResponseBody, &body) == nil {
+		entry.Shape = make(map[string]string)
+		responseShape(entry.Shape, "", body)
+	}
+	return entry, true
+}
+
+// writeBaseline stores results of all tests as baseline into file
+func writeBaseline(filename string, results []testResult) error {
+	baseline := Baseline{
+		Created: time.Now(),
+		Target:  apiURL,
+		Tests:   make(map[string]BaselineEntry),
+	}
+	for _, result := range results {
+		if entry, ok := newBaselineEntry(result); ok {
+			baseline.Tests[testKey(&result.Test)] = entry
+		}
+	}
+
+	content, err := json.MarshalIndent(baseline, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, content, 0644)
+}
+
+// readBaseline reads baseline from file
+func readBaseline(filename string) (Baseline, error) {
+	var baseline Baseline
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return baseline, err
+	}
+
+	err = json.Unmarshal(content, &baseline)
+	return baseline, err
+}
+
+// sortedKeys returns sorted keys of given map
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// baselineDifferences compares test result with baseline and returns list of
+// regressions
+func baselineDifferences(expected BaselineEntry, actual BaselineEntry) []string {
+	var problems []string
+
+	if expected.Status != actual.Status {
+		problems = append(problems, fmt.Sprintf("status code changed from %d to %d", expected.Status, actual.Status))
+	}
+
+	for _, name := range expected.Headers {
+		if !containsFold(actual.Headers, name) && !containsFold(transportHeaders, name) {
+			problems = append(problems, fmt.Sprintf("header %q was dropped", name))
+		}
+	}
+	for _, name := range actual.Headers {
+		if !containsFold(expected.Headers, name) && !containsFold(transportHeaders, name) {
+			problems = append(problems, fmt.Sprintf("new header %q appeared", name))
+		}
+	}
+
+	if expected.Shape != nil && actual.Shape == nil {
+		problems = append(problems, "response is not JSON anymore")
+	} else {
+		for _, path := range sortedKeys(expected.Shape) {
+			actualType, found := actual.Shape[path]
+			if !found {
+				problems = append(problems, fmt.Sprintf("field %s disappeared", path))
+			} else if actualType != expected.Shape[path] {
+				problems = append(problems, fmt.Sprintf("field %s changed type from %s to %s",
+					path, expected.Shape[path], actualType))
+			}
+		}
+		for _, path := range sortedKeys(actual.Shape) {
+			if _, found := expected.Shape[path]; !found {
+				problems = append(problems, fmt.Sprintf("new field %s appeared", path))
+			}
+		}
+	}
+
+	// short durations (including zero of tests without response) are noise
+	if expected.Duration >= minComparedDuration && actual.Duration >= minComparedDuration &&
+		float64(actual.Duration) > float64(expected.Duration)**slowdownFactor {
+		problems = append(problems, fmt.Sprintf("took %v, %.1f times longer than baseline %v",
+			actual.Duration, float64(actual.Duration)/float64(expected.Duration), expected.Duration))
+	}
+
+	return problems
+}
+
+// compareWithBaseline compares results of all tests with baseline.
+// Regressions are reported even for passing tests, they are counted as test
+// errors only when -fail-on-regression is set.
+func compareWithBaseline(filename string, results []testResult) error {
+	baseline, err := readBaseline(filename)
+	if err != nil {
+		return err
+	}
+
+	for i := range results {
+		result := &results[i]
+		expected, found := baseline.Tests[testKey(&result.Test)]
+		if !found {
+			continue
+		}
+		actual, ok := newBaselineEntry(*result)
+		if !ok {
+			// missing response is already reported by the test
+			continue
+		}
+		for _, problem := range baselineDifferences(expected, actual) {
+			regressions = append(regressions, regression{
+				Test:    result.Test.Message,
+				Problem: problem,
+			})
+			if *failOnRegression {
+				result.Errors = append(result.Errors, errors.New("Regression: "+problem))
+				frisby.Global.AddError(result.Test.Message, "Regression: "+problem)
+			}
+		}
+	}
+	return nil
+}
+
+// printRegressionReport prints all regressions found by comparison with
+// baseline
+func printRegressionReport() {
+	fmt.Println("\nRegressions")
+	if len(regressions) == 0 {
+		fmt.Println("  No regressions")
+		return
+	}
+
+	var tests []string
+	grouped := make(map[string][]string)
+	for _, regression := range regressions {
+		if _, found := grouped[regression.Test]; !found {
+			tests = append(tests, regression.Test)
+		}
+		grouped[regression.Test] = append(grouped[regression.Test], regression.Problem)
+	}
+
+	fmt.Printf("  REGRESSIONS  [%d]\n", len(regressions))
+	for _, test := range tests {
+		fmt.Printf("      [%s]\n", test)
+		for _, problem := range grouped[test] {
+			fmt.Println("        - ", problem)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBaselineHeaderDifferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []string
+		actual   []string
+		problems []string
+	}{
+		{"same headers", []string{"Content-Type", "Date"}, []string{"Content-Type", "Date"}, nil},
+		{"dropped header", []string{"Content-Type", "X-Request-Id"}, []string{"Content-Type"},
+			[]string{`header "X-Request-Id" was dropped`}},
+		{"new header", []string{"Content-Type"}, []string{"Content-Type", "Server"},
+			[]string{`new header "Server" appeared`}},
+		{"chunked instead of length", []string{"Content-Length", "Content-Type"},
+			[]string{"Content-Type", "Transfer-Encoding"}, nil},
+		{"connection headers", []string{"Connection", "Keep-Alive"}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := baselineDifferences(
+				BaselineEntry{Status: 200, Headers: tt.expected},
+				BaselineEntry{Status: 200, Headers: tt.actual})
+			if strings.Join(problems, "\n") != strings.Join(tt.problems, "\n") {
+				t.Errorf("expected problems %q, got %q", tt.problems, problems)
+			}
+		})
+	}
+}
+
+func TestBaselineDurationDifferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected time.Duration
+		actual   time.Duration
+		problems []string
+	}{
+		{"same duration", 20 * time.Millisecond, 20 * time.Millisecond, nil},
+		{"slower", 20 * time.Millisecond, 80 * time.Millisecond,
+			[]string{"took 80ms, 4.0 times longer than baseline 20ms"}},
+		{"slower within factor", 20 * time.Millisecond, 50 * time.Millisecond, nil},
+		{"short actual duration", time.Millisecond, 5 * time.Millisecond, nil},
+		{"short baseline duration", time.Millisecond, 11 * time.Millisecond, nil},
+		{"zero baseline duration", 0, time.Second, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := baselineDifferences(
+				BaselineEntry{Status: 200, Duration: tt.expected},
+				BaselineEntry{Status: 200, Duration: tt.actual})
+			if strings.Join(problems, "\n") != strings.Join(tt.problems, "\n") {
+				t.Errorf("expected problems %q, got %q", tt.problems, problems)
+			}
+		})
+	}
+}
+
+func TestCompareWithBaseline(t *testing.T) {
+	savedFlag, savedRegressions := *failOnRegression, regressions
+	defer func() {
+		*failOnRegression, regressions = savedFlag, savedRegressions
+	}()
+
+	dir, err := ioutil.TempDir("", "baseline-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	test := RestAPITest{Message: "Check the info endpoint", Endpoint: "info", Method: http.MethodGet}
+	filename := filepath.Join(dir, "baseline.json")
+	baseline := []testResult{{Test: test, Exchange: &exchange{
+		Response:     &http.Response{StatusCode: http.StatusOK, Header: http.Header{}},
+		ResponseBody: []byte(`{"status":"ok"}`),
+	}}}
+	if err := writeBaseline(filename, baseline); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fail := range []bool{false, true} {
+		*failOnRegression = fail
+		regressions = nil
+		results := []testResult{{Test: test, Exchange: &exchange{
+			Response:     &http.Response{StatusCode: http.StatusOK, Header: http.Header{}},
+			ResponseBody: []byte(`{"status":1}`),
+		}}}
+
+		if err := compareWithBaseline(filename, results); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := regression{Test: test.Message, Problem: "field status changed type from string to number"}
+		if len(regressions) != 1 || regressions[0] != expected {
+			t.Errorf("expected regression %v, got %v", expected, regressions)
+		}
+		if fail && len(results[0].Errors) != 1 {
+			t.Errorf("expected regression to be counted as test error, got %v", results[0].Errors)
+		}
+		if !fail && len(results[0].Errors) != 0 {
+			t.Errorf("expected passing test to stay passing, got %v", results[0].Errors)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/verdverm/frisby"
+)
+
+// headers that differ between any two responses and that are ignored by
+// default
+const defaultIgnoredHeaders = "Date"
+
+// wildcard that matches any key or index in JSON path
+const jsonPathWildcard = "*"
+
+// compareRules represents rules for fields and headers that are volatile and
+// that are not compared
+type compareRules struct {
+	IgnoredFields  []string
+	IgnoredHeaders []string
+}
+
+// splitList splits comma separated list, empty items are skipped
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// jsonPathMatches checks if path to JSON value matches given pattern.
+// Segments are separated by dots, wildcard matches any single segment.
+func jsonPathMatches(pattern string, path string) bool {
+	patternSegments := strings.Split(pattern, ".")
+	pathSegments := strings.Split(path, ".")
+
+	if len(patternSegments) != len(pathSegments) {
+		return false
+	}
+	for i, segment := range patternSegments {
+		if segment != jsonPathWildcard && segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonPathIgnored checks if path to JSON value matches any of given patterns
+func jsonPathIgnored(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if jsonPathMatches(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinJSONPath constructs path to nested JSON value
+func joinJSONPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// displayJSONPath returns path in format suitable for printing
+func displayJSONPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+// formatJSONValue returns JSON value in format suitable for printing
+func formatJSONValue(value interface{}) string {
+	text, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(text)
+}
+
+// jsonDifferences compares two JSON values and returns list of differences.
+// Values on ignored paths are not compared.
+func jsonDifferences(path string, a interface{}, b interface{}, ignored []string) []string {
+	if jsonPathIgnored(path, ignored) {
+		return nil
+	}
+
+	switch aValue := a.(type) {
+	case map[string]interface{}:
+		bValue, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		var differences []string
+		for _, key := range sortedUnion(aValue, bValue) {
+			keyPath := joinJSONPath(path, key)
+			aItem, aFound := aValue[key]
+			bItem, bFound := bValue[key]
+			switch {
+			case jsonPathIgnored(keyPath, ignored):
+			case !aFound:
+				differences = append(differences, fmt.Sprintf("%s: missing vs %s", keyPath, formatJSONValue(bItem)))
+			case !bFound:
+				differences = append(differences, fmt.Sprintf("%s: %s vs missing", keyPath, formatJSONValue(aItem)))
+			default:
+				differences = append(differences, jsonDifferences(keyPath, aItem, bItem, ignored)...)
+			}
+		}
+		return differences
+	case []interface{}:
+		bValue, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		var differences []string
+		if len(aValue) != len(bValue) {
+			differences = append(differences, fmt.Sprintf("%s: %d items vs %d items",
+				displayJSONPath(path), len(aValue), len(bValue)))
+		}
+		for i := 0; i < len(aValue) && i < len(bValue); i++ {
+			differences = append(differences,
+				jsonDifferences(joinJSONPath(path, strconv.Itoa(i)), aValue[i], bValue[i], ignored)...)
+		}
+		return differences
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		return []string{fmt.Sprintf("%s: %s vs %s", displayJSONPath(path), formatJSONValue(a), formatJSONValue(b))}
+	}
+	return nil
+}
+
+// sortedUnion returns sorted union of keys from both maps
+func sortedUnion(a map[string]interface{}, b map[string]interface{}) []string {
+	var keys []string
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, found := a[key]; !found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// headerDifferences compares two sets of headers, ignored headers are not
+// compared
+func headerDifferences(a http.Header, b http.Header, ignored []string) []string {
+	var differences []string
+
+	names := make(map[string]bool)
+	for name := range a {
+		names[name] = true
+	}
+	for name := range b {
+		names[name] = true
+	}
+
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	for _, name := range sortedNames {
+		if containsFold(ignored, name) {
+			continue
+		}
+		aValues := append([]string{}, a[name]...)
+		bValues := append([]string{}, b[name]...)
+		sort.Strings(aValues)
+		sort.Strings(bValues)
+		if !reflect.DeepEqual(aValues, bValues) {
+			differences = append(differences, fmt.Sprintf("header %q: %q vs %q", name, aValues, bValues))
+		}
+	}
+	return differences
+}
+
+// containsFold checks if list contains given string, case is ignored
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyDifferences compares two response bodies. JSON bodies are compared
+// structurally, other bodies need to be the same.
+func bodyDifferences(a []byte, b []byte, ignored []string) []string {
+	var aValue, bValue interface{}
+	aErr := json.Unmarshal(a, &aValue)
+	bErr := json.Unmarshal(b, &bValue)
+
+	if aErr == nil && bErr == nil {
+		var differences []string
+		for _, difference := range jsonDifferences("", aValue, bValue, ignored) {
+			differences = append(differences, "body "+difference)
+		}
+		return differences
+	}
+
+	if !bytes.Equal(a, b) {
+		return []string{fmt.Sprintf("body: %d bytes vs %d bytes with different content", len(a), len(b))}
+	}
+	return nil
+}
+
+// resultDifferences compares results of the same test performed against two
+// targets
+func resultDifferences(a testResult, b testResult, rules compareRules) []string {
+	aResp := a.Exchange.Response
+	bResp := b.Exchange.Response
+
+	switch {
+	case aResp == nil && bResp == nil:
+		return nil
+	case aResp == nil:
+		return []string{"no response vs " + bResp.Status}
+	case bResp == nil:
+		return []string{aResp.Status + " vs no response"}
+	}
+
+	var differences []string
+	if aResp.StatusCode != bResp.StatusCode {
+		differences = append(differences, fmt.Sprintf("status: %d vs %d", aResp.StatusCode, bResp.StatusCode))
+	}
+	differences = append(differences, headerDifferences(aResp.Header, bResp.Header, rules.IgnoredHeaders)...)
+	differences = append(differences,
+		bodyDifferences(a.Exchange.ResponseBody, b.Exchange.ResponseBody, rules.IgnoredFields)...)
+	return differences
+}
+
+// testDifferences represents differences found by one test performed against
+// two deployments
+type testDifferences struct {
+	Test        string
+	Differences []string
+}
+
+// checkEndPointOnTarget performs test against REST API on given base URL
+func checkEndPointOnTarget(test RestAPITest, baseURL string) testResult {
+	test.Message = fmt.Sprintf("%s (%s)", test.Message, baseURL)
+	return checkEndPointAt(&test, baseURL)
+}
+
+// compareTargets performs all tests against two deployments and returns
+// differences found by tests in order of tests, messages of tests don't need
+// to be unique
+func compareTargets(tests []RestAPITest, urlA string, urlB string, rules compareRules) []testDifferences {
+	var different []testDifferences
+
+	for _, test := range tests {
+		resultA := checkEndPointOnTarget(test, urlA)
+		resultB := checkEndPointOnTarget(test, urlB)
+
+		found := resultDifferences(resultA, resultB, rules)
+		if len(found) != 0 {
+			different = append(different, testDifferences{
+				Test:        test.Message,
+				Differences: found,
+			})
+		}
+	}
+	return different
+}
+
+// compareCommand implements the compare command that performs all tests
+// against two deployments and lists all differences found
+func compareCommand(args []string) int {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	ignoreFields := flags.String("ignore-fields", "",
+		"comma separated list of volatile JSON fields, like info.BuildTime or reports.*.created_at")
+	ignoreHeaders := flags.String("ignore-headers", defaultIgnoredHeaders,
+		"comma separated list of volatile headers")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: compare [-ignore-fields list] [-ignore-headers list] base-url-1 base-url-2")
+		return 2
+	}
+	urlA, urlB := normalizeBaseURL(flags.Arg(0)), normalizeBaseURL(flags.Arg(1))
+
+	rules := compareRules{
+		IgnoredFields:  splitList(*ignoreFields),
+		IgnoredHeaders: splitList(*ignoreHeaders),
+	}
+
+	tests, err := selectedTests()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	err = setupTargetTransport()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	different := compareTargets(tests, urlA, urlB, rules)
+
+	frisby.Global.PrintReport()
+
+	fmt.Printf("\nDifferences between %s and %s\n", urlA, urlB)
+	if len(different) == 0 {
+		fmt.Println("  No differences found")
+		return 0
+	}
+	fmt.Printf("  DIFFERENT  [%d/%d]\n", len(different), len(tests))
+	for _, test := range different {
+		fmt.Printf("      [%s]\n", test.Test)
+		for _, difference := range test.Differences {
+			fmt.Println("        - ", difference)
+		}
+	}
+	return 1
+}
@@ -0,0 +1,56 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// newStatusServer starts server that responds with status given for path
+func newStatusServer(t *testing.T, statuses map[string]int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statuses[r.URL.Path])
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCompareTargetsWithSameMessages(t *testing.T) {
+	serverA := newStatusServer(t, map[string]int{"/a": http.StatusOK, "/b": http.StatusOK, "/c": http.StatusOK})
+	serverB := newStatusServer(t, map[string]int{"/a": http.StatusCreated, "/b": http.StatusOK, "/c": http.StatusNotFound})
+
+	// the same message is used by more tests in the built-in table too
+	tests := []RestAPITest{
+		{Message: "Check endpoint", Endpoint: "a", Method: http.MethodGet, ExpectedStatus: http.StatusOK},
+		{Message: "Check endpoint", Endpoint: "b", Method: http.MethodGet, ExpectedStatus: http.StatusOK},
+		{Message: "Check endpoint", Endpoint: "c", Method: http.MethodGet, ExpectedStatus: http.StatusOK},
+	}
+	rules := compareRules{IgnoredHeaders: splitList(defaultIgnoredHeaders)}
+
+	different := compareTargets(tests, serverA.URL+"/", serverB.URL+"/", rules)
+
+	expected := []testDifferences{
+		{Test: "Check endpoint", Differences: []string{"status: 200 vs 201"}},
+		{Test: "Check endpoint", Differences: []string{"status: 200 vs 404"}},
+	}
+	if !reflect.DeepEqual(different, expected) {
+		t.Errorf("expected differences %q, got %q", expected, different)
+	}
+}
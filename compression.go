@@ -0,0 +1,233 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/verdverm/frisby"
+)
+
+// headers and encodings used by compressed responses
+const (
+	acceptEncodingHeader  = "Accept-Encoding"
+	contentEncodingHeader = "Content-Encoding"
+
+	EncodingGzip     = "gzip"
+	EncodingBrotli   = "br"
+	EncodingDeflate  = "deflate"
+	EncodingIdentity = "identity"
+
+	// value of Accept-Encoding header sent when compression is checked
+	acceptedEncodings = EncodingGzip + ", " + EncodingBrotli + ", " + EncodingDeflate
+)
+
+// compressedResponses enables compression for all requests
+var compressedResponses = flag.Bool("compressed", false,
+	"send 'Accept-Encoding: "+acceptedEncodings+"' with all requests and check Content-Encoding of responses")
+
+// Compression contains expectations about compression of response body
+type Compression struct {
+	// Encoding is expected value of Content-Encoding header, any accepted
+	// encoding is allowed when not set
+	Encoding string `json:",omitempty"`
+
+	// MinRatio is minimal ratio of decompressed and compressed body size
+	MinRatio float64 `json:",omitempty"`
+
+	// Threshold is size of body (in bytes) from which responses need to be
+	// compressed, smaller responses need to be left uncompressed
+	Threshold int `json:",omitempty"`
+}
+
+// failingReader returns stored error when read, it is used to keep error
+// found when body was read by runner for checkers that read body later
+type failingReader struct {
+	err error
+}
+
+// Read always returns stored error
+func (r failingReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+// decodeBody decompresses body according to given content encoding
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	var err error
+
+	switch strings.ToLower(encoding) {
+	case "", EncodingIdentity:
+		return body, nil
+	case EncodingGzip, "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+	case EncodingDeflate:
+		// deflate is supposed to be zlib stream, but some servers send raw
+		// deflate data
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			reader = flate.NewReader(bytes.NewReader(body))
+		}
+	case EncodingBrotli:
+		reader = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	return ioutil.ReadAll(reader)
+}
+
+// readResponseBody reads response body, compressed body is decompressed so
+// checkers reading body from Frisby object get decompressed content
+func readResponseBody(f *frisby.Frisby, e *exchange) {
+	resp := f.Resp.Response
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	e.WireBodySize = len(raw)
+	if err != nil {
+		e.BodyErr = err
+		resp.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(raw), failingReader{err}))
+		return
+	}
+
+	encoding := resp.Header.Get(contentEncodingHeader)
+	body, err := decodeBody(encoding, raw)
+	if err != nil {
+		e.BodyErr = fmt.Errorf("body can't be decompressed: %v", err)
+		resp.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(raw), failingReader{e.BodyErr}))
+		return
+	}
+
+	// Frisby decompresses body according to Content-Encoding header, but
+	// not all encodings are supported by it
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.Header.Del(contentEncodingHeader)
+	e.ResponseBody, _ = f.Resp.Content()
+	if encoding != "" {
+		resp.Header.Set(contentEncodingHeader, encoding)
+	}
+}
+
+// requestCompression sets Accept-Encoding header when compression is
+// checked
+func requestCompression(f *frisby.Frisby, test *RestAPITest) {
+	if *compressedResponses || test.Compression != nil {
+		f.SetHeader(acceptEncodingHeader, acceptedEncodings)
+	}
+}
+
+// isAcceptedEncoding checks if content encoding is listed in Accept-Encoding
+// header sent with request
+func isAcceptedEncoding(req *http.Request, encoding string) bool {
+	for _, accepted := range strings.Split(req.Header.Get(acceptEncodingHeader), ",") {
+		accepted = strings.TrimSpace(strings.Split(accepted, ";")[0])
+		if strings.EqualFold(accepted, encoding) || accepted == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCompression checks that response body is compressed by accepted
+// encoding and that it fulfills expectations of test
+func checkCompression(f *frisby.Frisby, test *RestAPITest, e *exchange) {
+	if !*compressedResponses && test.Compression == nil {
+		return
+	}
+
+	encoding := f.Resp.Header.Get(contentEncodingHeader)
+	if encoding == EncodingIdentity {
+		encoding = ""
+	}
+	if encoding != "" && e.Request != nil && !isAcceptedEncoding(e.Request, encoding) {
+		f.AddError(fmt.Sprintf("Header %s is %q, but this encoding was not accepted by request",
+			contentEncodingHeader, encoding))
+	}
+	if e.BodyErr != nil {
+		f.AddError(e.BodyErr.Error())
+		return
+	}
+
+	compression := test.Compression
+	if compression == nil {
+		return
+	}
+
+	size := len(e.ResponseBody)
+	if size < compression.Threshold {
+		if encoding != "" {
+			f.AddError(fmt.Sprintf("Expected response with %d bytes to be left uncompressed, but it is compressed by %s",
+				size, encoding))
+		}
+		return
+	}
+
+	if encoding == "" {
+		f.AddError(fmt.Sprintf("Expected response with %d bytes to be compressed, but header %s is not set",
+			size, contentEncodingHeader))
+		return
+	}
+	if compression.Encoding != "" && !strings.EqualFold(encoding, compression.Encoding) {
+		f.AddError(fmt.Sprintf("Expected Header %q to be %q, but got %q",
+			contentEncodingHeader, compression.Encoding, encoding))
+	}
+	if compression.MinRatio > 0 && e.WireBodySize > 0 {
+		ratio := float64(size) / float64(e.WireBodySize)
+		if ratio < compression.MinRatio {
+			f.AddError(fmt.Sprintf("Expected compression ratio to be at least %.2f, but got %.2f (%d bytes compressed to %d)",
+				compression.MinRatio, ratio, size, e.WireBodySize))
+		}
+	}
+}
+
+// lintCompression checks that expectations about compression are correct
+func lintCompression(test *RestAPITest) []string {
+	compression := test.Compression
+	if compression == nil {
+		return nil
+	}
+
+	var problems []string
+	switch strings.ToLower(compression.Encoding) {
+	case "", EncodingGzip, EncodingBrotli, EncodingDeflate:
+	default:
+		problems = append(problems, fmt.Sprintf("Encoding %q is not one of %s", compression.Encoding, acceptedEncodings))
+	}
+	if compression.MinRatio < 0 {
+		problems = append(problems, "MinRatio is negative")
+	}
+	if compression.Threshold < 0 {
+		problems = append(problems, "Threshold is negative")
+	}
+	if test.Method == http.MethodHead {
+		problems = append(problems, "Compression is set, but response to HEAD method has no body")
+	}
+	return problems
+}
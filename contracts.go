@@ -0,0 +1,278 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/verdverm/frisby"
+)
+
+// JSON types that can be required by contracts, empty type means any type
+const (
+	JSONTypeAny     = ""
+	JSONTypeString  = "string"
+	JSONTypeNumber  = "number"
+	JSONTypeBoolean = "boolean"
+	JSONTypeObject  = "object"
+	JSONTypeArray   = "array"
+	JSONTypeNull    = "null"
+)
+
+// Contract represents requests made by one consumer of REST API together
+// with response fields the consumer relies on
+type Contract struct {
+	Consumer     string
+	Interactions []ContractInteraction
+}
+
+// ContractInteraction represents one request made by consumer. Fields map
+// paths to response fields (with * wildcard for any key or array item) to
+// JSON type the consumer expects.
+type ContractInteraction struct {
+	Description            string
+	Endpoint               string
+	Method                 string
+	AuthHeader             bool
+	AuthHeaderOrganization int `json:",omitempty"`
+	ExpectedStatus         int
+	Fields                 map[string]string
+}
+
+// readContractFile reads contract from JSON file
+func readContractFile(filename string) (Contract, error) {
+	var contract Contract
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return contract, err
+	}
+
+	err = json.Unmarshal(content, &contract)
+	if err != nil {
+		return contract, err
+	}
+	if contract.Consumer == "" {
+		return contract, fmt.Errorf("%s: consumer is not specified", filename)
+	}
+	return contract, nil
+}
+
+// jsonType returns name of type of decoded JSON value
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return JSONTypeString
+	case float64:
+		return JSONTypeNumber
+	case bool:
+		return JSONTypeBoolean
+	case map[string]interface{}:
+		return JSONTypeObject
+	case []interface{}:
+		return JSONTypeArray
+	default:
+		return JSONTypeNull
+	}
+}
+
+// jsonFieldProblems checks that field on given path exists in decoded JSON
+// value and that it has expected type. Wildcard in path requires the field
+// in every item of array or object.
+func jsonFieldProblems(value interface{}, path string, segments []string, expectedType string) []string {
+	if len(segments) == 0 {
+		if expectedType != JSONTypeAny && jsonType(value) != expectedType {
+			return []string{fmt.Sprintf("field %s is %s, but %s is expected",
+				displayJSONPath(path), jsonType(value), expectedType)}
+		}
+		return nil
+	}
+
+	segment := segments[0]
+	var problems []string
+
+	switch node := value.(type) {
+	case map[string]interface{}:
+		if segment == jsonPathWildcard {
+			for key, item := range node {
+				problems = append(problems,
+					jsonFieldProblems(item, joinJSONPath(path, key), segments[1:], expectedType)...)
+			}
+			return problems
+		}
+		item, found := node[segment]
+		if !found {
+			return []string{fmt.Sprintf("field %s is missing", joinJSONPath(path, segment))}
+		}
+		return jsonFieldProblems(item, joinJSONPath(path, segment), segments[1:], expectedType)
+	case []interface{}:
+		if segment != jsonPathWildcard {
+			return []string{fmt.Sprintf("field %s is array, use %s to select its items",
+				displayJSONPath(path), jsonPathWildcard)}
+		}
+		for i, item := range node {
+			problems = append(problems,
+				jsonFieldProblems(item, joinJSONPath(path, fmt.Sprint(i)), segments[1:], expectedType)...)
+		}
+		return problems
+	default:
+		return []string{fmt.Sprintf("field %s is %s, so it can't contain %s",
+			displayJSONPath(path), jsonType(value), strings.Join(segments, "."))}
+	}
+}
+
+// contractFieldsChecker returns checker that verifies that response contains
+// all fields the consumer relies on
+func contractFieldsChecker(fields map[string]string) func(f *frisby.Frisby) {
+	return func(f *frisby.Frisby) {
+		text, err := f.Resp.Content()
+		if err != nil {
+			f.AddError(err.Error())
+			return
+		}
+
+		var body interface{}
+		err = json.Unmarshal(text, &body)
+		if err != nil {
+			f.AddError("Response is not JSON: " + err.Error())
+			return
+		}
+
+		for _, problem := range schemaProblems(body, fields) {
+			f.AddError(problem)
+		}
+	}
+}
+
+// schemaProblems checks that decoded JSON value contains all fields from
+// schema, that maps paths of fields to expected JSON types
+func schemaProblems(value interface{}, schema map[string]string) []string {
+	var problems []string
+	for _, path := range sortedKeys(schema) {
+		problems = append(problems, jsonFieldProblems(value, "", strings.Split(path, "."), schema[path])...)
+	}
+	return problems
+}
+
+// contractTest converts interaction from contract into REST API test
+func contractTest(consumer string, interaction ContractInteraction) RestAPITest {
+	return RestAPITest{
+		Message:                fmt.Sprintf("[%s] %s", consumer, interaction.Description),
+		Endpoint:               interaction.Endpoint,
+		Method:                 interaction.Method,
+		AuthHeader:             interaction.AuthHeader,
+		AuthHeaderOrganization: interaction.AuthHeaderOrganization,
+		ExpectedStatus:         interaction.ExpectedStatus,
+		AdditionalChecker:      contractFieldsChecker(interaction.Fields),
+	}
+}
+
+// printFieldImpact prints all consumers that rely on given response field
+func printFieldImpact(contracts []Contract, field string) {
+	fmt.Printf("\nConsumers relying on field %s\n", field)
+
+	found := false
+	for _, contract := range contracts {
+		for _, interaction := range contract.Interactions {
+			for path := range interaction.Fields {
+				if path == field || jsonPathMatches(path, field) {
+					fmt.Printf("  [%s] %s %s (%s)\n", contract.Consumer,
+						interaction.Method, interaction.Endpoint, interaction.Description)
+					found = true
+				}
+			}
+		}
+	}
+	if !found {
+		fmt.Println("  No consumer relies on this field")
+	}
+}
+
+// contractsCommand implements the contracts command that verifies all
+// contracts against the provider and reports consumers that would break
+func contractsCommand(args []string) int {
+	flags := flag.NewFlagSet("contracts", flag.ExitOnError)
+	field := flags.String("field", "",
+		"don't verify contracts, just list consumers relying on given response field")
+	_ = flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: contracts [-field path] contract.json ...")
+		return 2
+	}
+
+	var contracts []Contract
+	for _, filename := range flags.Args() {
+		contract, err := readContractFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		contracts = append(contracts, contract)
+	}
+
+	if *field != "" {
+		printFieldImpact(contracts, *field)
+		return 0
+	}
+
+	err := setupTargetTransport()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	broken := make(map[string][]testResult)
+	var consumers []string
+
+	for _, contract := range contracts {
+		for _, interaction := range contract.Interactions {
+			test := contractTest(contract.Consumer, interaction)
+			result := checkEndPoint(&test)
+			if !result.Passed() {
+				if _, found := broken[contract.Consumer]; !found {
+					consumers = append(consumers, contract.Consumer)
+				}
+				broken[contract.Consumer] = append(broken[contract.Consumer], result)
+			}
+		}
+	}
+
+	frisby.Global.PrintReport()
+
+	fmt.Println("\nContracts")
+	if len(consumers) == 0 {
+		fmt.Printf("  All %d contracts are satisfied\n", len(contracts))
+		return 0
+	}
+	fmt.Printf("  BROKEN  [%d/%d]\n", len(consumers), len(contracts))
+	for _, consumer := range consumers {
+		fmt.Printf("      [%s would break]\n", consumer)
+		for _, result := range broken[consumer] {
+			fmt.Printf("        %s %s\n", result.Test.Method, result.Test.Endpoint)
+			for _, err := range result.Errors {
+				fmt.Println("          - ", err)
+			}
+		}
+	}
+	return 1
+}
@@ -0,0 +1,215 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"time"
+
+	"github.com/verdverm/frisby"
+	"golang.org/x/net/publicsuffix"
+)
+
+// name of cookie jar shared by all tests that don't select own group
+const suiteCookieJar = "suite"
+
+// values of SameSite cookie attribute that can be expected
+const (
+	SameSiteLax    = "Lax"
+	SameSiteStrict = "Strict"
+	SameSiteNone   = "None"
+)
+
+// useSuiteCookieJar enables cookie jar shared by all tests
+var useSuiteCookieJar = flag.Bool("cookie-jar", false,
+	"share cookies between all tests; tests with CookieJar set use own group jar")
+
+// cookie jars shared between tests, indexed by group name
+var cookieJars = make(map[string]http.CookieJar)
+
+// ExpectedCookie represents cookie that needs to be set by response. Only
+// attributes with non-zero value are checked.
+type ExpectedCookie struct {
+	Name string
+
+	// Value is exact value, regular expression (with HeaderRegexPrefix) or
+	// HeaderPresent
+	Value    string `json:",omitempty"`
+	Secure   bool   `json:",omitempty"`
+	HttpOnly bool   `json:",omitempty"`
+	SameSite string `json:",omitempty"`
+	Path     string `json:",omitempty"`
+
+	// MinLifetime is minimal time until cookie expires, Session requires
+	// cookie without expiry
+	MinLifetime Duration `json:",omitempty"`
+	Session     bool     `json:",omitempty"`
+}
+
+// cookieJarForGroup returns cookie jar for given group, the jar is created
+// on first use
+func cookieJarForGroup(group string) http.CookieJar {
+	jar, found := cookieJars[group]
+	if !found {
+		options := cookiejar.Options{
+			PublicSuffixList: publicsuffix.List,
+		}
+		// error is never returned by current implementation
+		jar, _ = cookiejar.New(&options)
+		cookieJars[group] = jar
+	}
+	return jar
+}
+
+// useCookieJar sets cookie jar shared with other tests from the same group.
+// Frisby object uses its own jar otherwise, so cookies are not preserved.
+func useCookieJar(f *frisby.Frisby, test *RestAPITest) {
+	group := test.CookieJar
+	if group == "" && *useSuiteCookieJar {
+		group = suiteCookieJar
+	}
+	if group != "" {
+		f.Req.Client.Jar = cookieJarForGroup(group)
+		// the HTTP library would store cookies from jar again with path of
+		// current request, so they would be sent twice by next requests
+		if len(f.Req.Cookies) == 0 {
+			f.Req.Cookies = nil
+		}
+	}
+}
+
+// sameSiteName returns name of SameSite attribute as used in Set-Cookie
+// header
+func sameSiteName(sameSite http.SameSite) string {
+	switch sameSite {
+	case http.SameSiteLaxMode:
+		return SameSiteLax
+	case http.SameSiteStrictMode:
+		return SameSiteStrict
+	case http.SameSiteNoneMode:
+		return SameSiteNone
+	default:
+		return ""
+	}
+}
+
+// cookieExpiration returns time when cookie expires, false is returned for
+// session cookies
+func cookieExpiration(cookie *http.Cookie, now time.Time) (time.Time, bool) {
+	switch {
+	case cookie.MaxAge > 0:
+		return now.Add(time.Duration(cookie.MaxAge) * time.Second), true
+	case cookie.MaxAge < 0:
+		return now, true
+	case cookie.RawExpires != "":
+		return cookie.Expires, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// checkCookie checks attributes of one cookie set by response
+func checkCookie(f *frisby.Frisby, expected ExpectedCookie, cookie *http.Cookie) {
+	if ok, err := matchHeaderValue(expected.Value, cookie.Value); err != nil {
+		f.AddError(fmt.Sprintf("Improper matcher for cookie %q: %v", expected.Name, err))
+	} else if !ok {
+		f.AddError(fmt.Sprintf("Expected cookie %q to have %s, but got %q",
+			expected.Name, describeHeaderMatcher(expected.Value), cookie.Value))
+	}
+
+	if expected.Secure && !cookie.Secure {
+		f.AddError(fmt.Sprintf("Expected cookie %q to be Secure", expected.Name))
+	}
+
+	if expected.HttpOnly && !cookie.HttpOnly {
+		f.AddError(fmt.Sprintf("Expected cookie %q to be HttpOnly", expected.Name))
+	}
+
+	if expected.SameSite != "" && expected.SameSite != sameSiteName(cookie.SameSite) {
+		f.AddError(fmt.Sprintf("Expected cookie %q to have SameSite=%s, but got %q",
+			expected.Name, expected.SameSite, sameSiteName(cookie.SameSite)))
+	}
+
+	if expected.Path != "" && expected.Path != cookie.Path {
+		f.AddError(fmt.Sprintf("Expected cookie %q to have Path %q, but got %q",
+			expected.Name, expected.Path, cookie.Path))
+	}
+
+	now := time.Now()
+	expiration, expires := cookieExpiration(cookie, now)
+
+	if expected.Session && expires {
+		f.AddError(fmt.Sprintf("Expected cookie %q to be session cookie, but it expires at %v",
+			expected.Name, expiration))
+	}
+
+	if expected.MinLifetime != 0 {
+		if !expires {
+			f.AddError(fmt.Sprintf("Expected cookie %q to expire in %v at least, but it is session cookie",
+				expected.Name, expected.MinLifetime))
+		} else if expiration.Before(now.Add(time.Duration(expected.MinLifetime))) {
+			f.AddError(fmt.Sprintf("Expected cookie %q to expire in %v at least, but it expires at %v",
+				expected.Name, expected.MinLifetime, expiration))
+		}
+	}
+}
+
+// checkExpectedCookies checks that all expected cookies were set by response
+// with given attributes
+func checkExpectedCookies(f *frisby.Frisby, expected []ExpectedCookie) {
+	if len(expected) == 0 {
+		return
+	}
+
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range f.Resp.Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+
+	for _, expectedCookie := range expected {
+		cookie, found := cookies[expectedCookie.Name]
+		if !found {
+			f.AddError(fmt.Sprintf("Expected cookie %q to be set, but it was missing", expectedCookie.Name))
+			continue
+		}
+		checkCookie(f, expectedCookie, cookie)
+	}
+}
+
+// lintExpectedCookies checks that expected cookies are specified properly
+func lintExpectedCookies(test *RestAPITest) []string {
+	var problems []string
+
+	for _, cookie := range test.ExpectedCookies {
+		if cookie.Name == "" {
+			problems = append(problems, "expected cookie without name")
+		}
+		switch cookie.SameSite {
+		case "", SameSiteLax, SameSiteStrict, SameSiteNone:
+		default:
+			problems = append(problems, fmt.Sprintf("cookie %q: unknown SameSite value %q", cookie.Name, cookie.SameSite))
+		}
+		if cookie.Session && cookie.MinLifetime != 0 {
+			problems = append(problems, fmt.Sprintf("cookie %q: both Session and MinLifetime are set", cookie.Name))
+		}
+	}
+
+	return problems
+}
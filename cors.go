@@ -0,0 +1,209 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/verdverm/frisby"
+)
+
+// headers used by CORS preflight requests and responses
+const (
+	originHeader                      = "Origin"
+	accessControlRequestMethodHeader  = "Access-Control-Request-Method"
+	accessControlRequestHeadersHeader = "Access-Control-Request-Headers"
+	accessControlAllowOriginHeader    = "Access-Control-Allow-Origin"
+	accessControlAllowMethodsHeader   = "Access-Control-Allow-Methods"
+	accessControlAllowHeadersHeader   = "Access-Control-Allow-Headers"
+)
+
+// CORS describes cross-origin requests that endpoint needs to allow or
+// reject
+type CORS struct {
+	// Origin is sent with preflight request, it needs to be allowed
+	Origin string
+
+	// RequestMethod and RequestHeaders are sent in preflight request,
+	// method of test is used when RequestMethod is not set
+	RequestMethod  string   `json:",omitempty"`
+	RequestHeaders []string `json:",omitempty"`
+
+	// ExpectedHeaders maps headers of preflight response to matchers, the
+	// same matchers as in ExpectedHeaders of test are supported
+	ExpectedHeaders map[string]string `json:",omitempty"`
+
+	// DisallowedOrigins contains origins that need to be rejected
+	DisallowedOrigins []string `json:",omitempty"`
+}
+
+// listContainsFold checks if comma separated list from header contains
+// given item, wildcard matches any item
+func listContainsFold(list string, item string) bool {
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "*" || strings.EqualFold(value, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// preflightMethod returns method of request that preflight request asks
+// for
+func preflightMethod(test *RestAPITest) string {
+	if test.CORS.RequestMethod != "" {
+		return test.CORS.RequestMethod
+	}
+	return test.Method
+}
+
+// sendPreflight sends CORS preflight request from given origin to URL.
+// Preflight requests are sent by browsers without credentials, so no
+// authorization header is used.
+func sendPreflight(test *RestAPITest, endpointURL string, origin string) (*frisby.Frisby, *exchange) {
+	cors := test.CORS
+
+	f := frisby.Create(fmt.Sprintf("%s (CORS preflight from %s)", test.Message, origin))
+	f.Method = http.MethodOptions
+	f.Url = endpointURL
+	f.SetHeader(originHeader, origin)
+	f.SetHeader(accessControlRequestMethodHeader, preflightMethod(test))
+	if len(cors.RequestHeaders) != 0 {
+		f.SetHeader(accessControlRequestHeadersHeader, strings.Join(cors.RequestHeaders, ", "))
+	}
+
+	return f, sendRequest(f)
+}
+
+// checkAllowedOrigin checks that preflight request from allowed origin
+// succeeded and that Access-Control-Allow-* headers allow the request
+func checkAllowedOrigin(f *frisby.Frisby, test *RestAPITest) {
+	cors := test.CORS
+	method := preflightMethod(test)
+
+	if f.Resp.StatusCode < 200 || f.Resp.StatusCode >= 300 {
+		f.AddError(fmt.Sprintf("Expected preflight request to succeed, but got %q", f.Resp.Status))
+	}
+
+	allowedOrigin := f.Resp.Header.Get(accessControlAllowOriginHeader)
+	if allowedOrigin != cors.Origin && allowedOrigin != "*" {
+		f.AddError(fmt.Sprintf("Expected Header %q to be %q, but got %q",
+			accessControlAllowOriginHeader, cors.Origin, allowedOrigin))
+	}
+
+	allowedMethods := f.Resp.Header.Get(accessControlAllowMethodsHeader)
+	if !listContainsFold(allowedMethods, method) {
+		f.AddError(fmt.Sprintf("Expected Header %q to contain %q, but got %q",
+			accessControlAllowMethodsHeader, method, allowedMethods))
+	}
+
+	allowedHeaders := f.Resp.Header.Get(accessControlAllowHeadersHeader)
+	for _, header := range cors.RequestHeaders {
+		if !listContainsFold(allowedHeaders, header) {
+			f.AddError(fmt.Sprintf("Expected Header %q to contain %q, but got %q",
+				accessControlAllowHeadersHeader, header, allowedHeaders))
+		}
+	}
+
+	checkExpectedHeaders(f, cors.ExpectedHeaders)
+}
+
+// checkDisallowedOrigin checks that preflight request from disallowed origin
+// was rejected
+func checkDisallowedOrigin(f *frisby.Frisby, origin string) {
+	if f.Resp.StatusCode < 200 || f.Resp.StatusCode >= 300 {
+		return
+	}
+
+	allowedOrigin := f.Resp.Header.Get(accessControlAllowOriginHeader)
+	switch allowedOrigin {
+	case origin:
+		f.AddError(fmt.Sprintf("Expected origin %q to be rejected, but it is allowed", origin))
+	case "*":
+		f.AddError(fmt.Sprintf("Expected origin %q to be rejected, but header %q allows any origin",
+			origin, accessControlAllowOriginHeader))
+	}
+}
+
+// checkCORS sends preflight requests from allowed and disallowed origins to
+// given URL and checks responses, errors found are returned
+func checkCORS(test *RestAPITest, endpointURL string) []error {
+	var problems []error
+
+	f, e := sendPreflight(test, endpointURL, test.CORS.Origin)
+	if !checkRequestFailed(f) {
+		checkAllowedOrigin(f, test)
+		checkErrorPayload(f, false)
+		auditResponse(f, e, test.AuditSeverities)
+	}
+	reportTest(f, e)
+	problems = append(problems, f.Errors()...)
+
+	for _, origin := range test.CORS.DisallowedOrigins {
+		f, e := sendPreflight(test, endpointURL, origin)
+		if !checkRequestFailed(f) {
+			checkDisallowedOrigin(f, origin)
+			checkErrorPayload(f, false)
+			auditResponse(f, e, test.AuditSeverities)
+		}
+		reportTest(f, e)
+		problems = append(problems, f.Errors()...)
+	}
+	return problems
+}
+
+// lintOrigin checks that origin consists of scheme, host and optional port
+func lintOrigin(field string, origin string) []string {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return []string{fmt.Sprintf("%s %q is not proper origin", field, origin)}
+	}
+	return nil
+}
+
+// lintCORS checks that CORS requests are specified properly
+func lintCORS(test *RestAPITest) []string {
+	cors := test.CORS
+	if cors == nil {
+		return nil
+	}
+
+	var problems []string
+	if cors.Origin == "" {
+		problems = append(problems, "CORS needs Origin")
+	} else {
+		problems = append(problems, lintOrigin("Origin", cors.Origin)...)
+	}
+
+	if cors.RequestMethod != "" && !isSupportedMethod(cors.RequestMethod) {
+		problems = append(problems, fmt.Sprintf("unsupported CORS request method %q", cors.RequestMethod))
+	}
+
+	problems = append(problems, lintHeaderRegexps("CORS", cors.ExpectedHeaders)...)
+
+	for _, origin := range cors.DisallowedOrigins {
+		problems = append(problems, lintOrigin("disallowed origin", origin)...)
+		if origin == cors.Origin {
+			problems = append(problems, fmt.Sprintf("origin %q is both allowed and disallowed", origin))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,121 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/verdverm/frisby"
+)
+
+// replacement for secret values in printed curl commands
+const redactedValue = "REDACTED"
+
+// command line flags driving curl output
+var (
+	printCurl = flag.Bool("print-curl", false,
+		"print equivalent curl command for each request (always printed for failed tests)")
+	redactSecrets = flag.Bool("redact-secrets", false,
+		"redact authorization headers and cookies in printed curl commands")
+)
+
+// headers that contain secrets and that are redacted on demand
+var secretHeaders = []string{
+	authHeaderName,
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+}
+
+// isSecretHeader checks if given header contains secret value
+func isSecretHeader(name string) bool {
+	for _, secret := range secretHeaders {
+		if strings.EqualFold(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote quotes given string to be used as one shell word
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// curlCommand constructs curl command equivalent to recorded request. The
+// command is preceded by comments with decoded identity.
+func curlCommand(e *exchange, redact bool) string {
+	var comments []string
+	var lines []string
+	req := e.Request
+
+	// identity is base64 encoded, so decode it to make it readable
+	if !redact {
+		for _, value := range req.Header[http.CanonicalHeaderKey(authHeaderName)] {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err == nil {
+				comments = append(comments, fmt.Sprintf("# %s: %s\n", authHeaderName, decoded))
+			}
+		}
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		lines = append(lines, "curl "+shellQuote(req.URL.String()))
+	case http.MethodHead:
+		lines = append(lines, "curl --head "+shellQuote(req.URL.String()))
+	default:
+		lines = append(lines, fmt.Sprintf("curl -X %s %s", req.Method, shellQuote(req.URL.String())))
+	}
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range req.Header[name] {
+			if redact && isSecretHeader(name) {
+				value = redactedValue
+			}
+			lines = append(lines, "  -H "+shellQuote(name+": "+value))
+		}
+	}
+
+	if len(e.RequestBody) > 0 {
+		lines = append(lines, "  --data-raw "+shellQuote(string(e.RequestBody)))
+	}
+
+	return strings.Join(comments, "") + strings.Join(lines, " \\\n")
+}
+
+// printCurlCommand prints curl command for recorded request when it was
+// requested by user or when the test failed
+func printCurlCommand(f *frisby.Frisby, e *exchange) {
+	if e.Request == nil {
+		return
+	}
+	if *printCurl || len(f.Errs) > 0 {
+		fmt.Println(curlCommand(e, *redactSecrets))
+	}
+}
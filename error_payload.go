@@ -0,0 +1,146 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/verdverm/frisby"
+)
+
+// error payload settings read from command line
+var (
+	checkErrorPayloads = flag.Bool("error-payloads", false,
+		"check that all non-2xx JSON responses match error schema and that errors are not returned as plain text where JSON is expected")
+	errorSchemaFile = flag.String("error-schema", "",
+		"JSON file mapping paths of fields to JSON types that all error payloads need to contain, implies -error-payloads")
+)
+
+// errorSchema maps paths of fields to JSON types that all error payloads
+// need to contain, by default it is the shape of StatusOnlyResponse
+var errorSchema = map[string]string{
+	"status": JSONTypeString,
+}
+
+// known JSON types that can be used in error schema
+var jsonTypes = []string{
+	JSONTypeAny,
+	JSONTypeString,
+	JSONTypeNumber,
+	JSONTypeBoolean,
+	JSONTypeObject,
+	JSONTypeArray,
+	JSONTypeNull,
+}
+
+// readErrorSchema reads error schema from JSON file
+func readErrorSchema(filename string) (map[string]string, error) {
+	var schema map[string]string
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, &schema)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range sortedKeys(schema) {
+		if path == "" {
+			return nil, fmt.Errorf("%s: path of field is empty", filename)
+		}
+		if !containsFold(jsonTypes, schema[path]) {
+			return nil, fmt.Errorf("%s: field %s has unknown type %q", filename, path, schema[path])
+		}
+	}
+	return schema, nil
+}
+
+// setupErrorSchema reads error schema from file given on command line, if
+// any, and enables checks of error payloads
+func setupErrorSchema() error {
+	if *errorSchemaFile == "" {
+		return nil
+	}
+	schema, err := readErrorSchema(*errorSchemaFile)
+	if err != nil {
+		return err
+	}
+	errorSchema = schema
+	*checkErrorPayloads = true
+	return nil
+}
+
+// isJSONMediaType checks if content type denotes JSON, including types with
+// +json suffix
+func isJSONMediaType(contentType string) bool {
+	mediaType := mediaTypeOf(contentType)
+	return mediaType == ContentTypeJSONWithoutCharset || strings.HasSuffix(mediaType, "+json")
+}
+
+// expectsJSON checks if test expects JSON response
+func expectsJSON(test *RestAPITest) bool {
+	return test.ExpectedResponseStatus != None || isJSONMediaType(test.ExpectedContentType)
+}
+
+// checkErrorPayload checks that error response uses JSON payload matching
+// error schema. Errors returned in other format are reported only when JSON
+// is expected. Responses without body (to HEAD requests, 304 Not Modified
+// and empty ones) have no payload to check.
+func checkErrorPayload(f *frisby.Frisby, jsonExpected bool) {
+	if !*checkErrorPayloads || (f.Resp.StatusCode >= 200 && f.Resp.StatusCode < 300) {
+		return
+	}
+	if f.Method == http.MethodHead || f.Resp.StatusCode == http.StatusNotModified {
+		return
+	}
+
+	text, err := f.Resp.Content()
+	if err != nil {
+		f.AddError(err.Error())
+		return
+	}
+	if len(text) == 0 {
+		return
+	}
+
+	contentType := f.Resp.Header.Get(contentTypeHeader)
+	if !isJSONMediaType(contentType) {
+		if jsonExpected {
+			f.AddError(fmt.Sprintf("Error response %q is %q, but JSON is expected", f.Resp.Status, contentType))
+		}
+		return
+	}
+
+	var body interface{}
+	err = json.Unmarshal(text, &body)
+	if err != nil {
+		f.AddError("Error payload is not JSON: " + err.Error())
+		return
+	}
+
+	for _, problem := range schemaProblems(body, errorSchema) {
+		f.AddError("Error payload does not match schema: " + problem)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/verdverm/frisby"
+)
+
+func TestCheckErrorPayload(t *testing.T) {
+	saved := *checkErrorPayloads
+	*checkErrorPayloads = true
+	defer func() {
+		*checkErrorPayloads = saved
+	}()
+
+	// status, content type and body of response are taken from query
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		w.Header().Set(contentTypeHeader, r.URL.Query().Get("type"))
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(r.URL.Query().Get("body")))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		method       string
+		query        string
+		jsonExpected bool
+		problems     []string
+	}{
+		{"matching payload", http.MethodGet, `status=400&type=application/json&body={"status":"bad"}`, true, nil},
+		{"success", http.MethodGet, "status=200&type=text/plain&body=ok", true, nil},
+		{"payload not matching schema", http.MethodGet, `status=404&type=application/json&body={"error":"x"}`, false,
+			[]string{"Error payload does not match schema: field status is missing"}},
+		{"payload not JSON", http.MethodGet, "status=500&type=application/json&body=oops", false,
+			[]string{"Error payload is not JSON: invalid character 'o' looking for beginning of value"}},
+		{"plain text where JSON is expected", http.MethodGet, "status=500&type=text/plain&body=oops", true,
+			[]string{`Error response "500 Internal Server Error" is "text/plain", but JSON is expected`}},
+		{"plain text", http.MethodGet, "status=500&type=text/plain&body=oops", false, nil},
+		{"HEAD request", http.MethodHead, "status=404&type=application/json&body=oops", true, nil},
+		{"not modified", http.MethodGet, "status=304&type=application/json", true, nil},
+		{"empty body", http.MethodGet, "status=404&type=application/json", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := frisby.Create(tt.name)
+			f.Method = tt.method
+			f.Url = server.URL + "/?" + strings.NewReplacer("{", "%7B", "}", "%7D", `"`, "%22").Replace(tt.query)
+			f.Send()
+			if f.Resp == nil || f.Resp.Response == nil {
+				t.Fatalf("no response: %v", f.Error())
+			}
+
+			checkErrorPayload(f, tt.jsonExpected)
+
+			var problems []string
+			for _, err := range f.Errors() {
+				problems = append(problems, err.Error())
+			}
+			if strings.Join(problems, "\n") != strings.Join(tt.problems, "\n") {
+				t.Errorf("expected problems %q, got %q", tt.problems, problems)
+			}
+		})
+	}
+}
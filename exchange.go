@@ -0,0 +1,129 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/verdverm/frisby"
+)
+
+// exchange represents one HTTP request sent by Frisby together with the
+// response. It is filled by hooks called by the underlying HTTP library,
+// response body is stored as read (and decompressed) by Frisby.
+type exchange struct {
+	Request      *http.Request
+	RequestBody  []byte
+	Response     *http.Response
+	ResponseBody []byte
+	Err          error
+	Started      time.Time
+	Timing       requestTiming
+
+	// WireBodySize is size of response body as transferred, it differs
+	// from size of ResponseBody for compressed responses
+	WireBodySize int
+
+	// BodyErr is error found when response body was read or decompressed
+	BodyErr error
+}
+
+// BeforeRequest is called before the request is sent. It stores the request
+// and a copy of its body, the request is replaced by the one recorded by
+// tracing transport when it is sent.
+func (e *exchange) BeforeRequest(req *http.Request) (*http.Response, error) {
+	e.Request = req
+	e.Started = time.Now()
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err == nil {
+			e.RequestBody, _ = ioutil.ReadAll(body)
+			_ = body.Close()
+		}
+	}
+	return nil, nil
+}
+
+// AfterRequest is called after the response is received. It stores the
+// response, body is read lazily by Frisby checks.
+func (e *exchange) AfterRequest(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	e.Response = resp
+	e.Err = err
+	return nil, nil
+}
+
+// recordExchange registers hooks that record request and response made by
+// given Frisby object
+func recordExchange(f *frisby.Frisby) *exchange {
+	e := &exchange{}
+	f.Req.Hooks = append(f.Req.Hooks, e)
+	return e
+}
+
+// sendRequest performs request prepared in Frisby object. Request and
+// response are recorded together with timing breakdown. Response body is read
+// by tracing transport so the total time includes body transfer.
+func sendRequest(f *frisby.Frisby) *exchange {
+	e := recordExchange(f)
+	useTargetTransport(f)
+	traceRequest(f, e)
+	releaseLockWhileSending(f)
+
+	f.Send()
+
+	if f.Resp != nil && f.Resp.Response != nil {
+		// possible error is reported by checkers that read the body
+		readResponseBody(f, e)
+	}
+	return e
+}
+
+// checkRequestFailed checks if the request failed, for example because
+// connection or TLS handshake was not successful, so no response is
+// available. Such failure is counted as test error.
+func checkRequestFailed(f *frisby.Frisby) bool {
+	if f.Resp != nil && f.Resp.Response != nil {
+		return false
+	}
+
+	err := f.Error()
+	if err == nil {
+		f.AddError(fmt.Sprintf("No response received for %s request", f.Method))
+	} else {
+		// error is already stored in Frisby object by Send
+		frisby.Global.AddError(f.Name, err.Error())
+	}
+	return true
+}
+
+// reportTest prints overall status of test to terminal together with all
+// outputs derived from recorded request and response
+func reportTest(f *frisby.Frisby, e *exchange) {
+	// all outputs of the test need to be printed together
+	waitForSUTLog(f, e)
+
+	f.PrintReport()
+	if e.Request != nil {
+		printTiming(f, e.Timing)
+	}
+	printCurlCommand(f, e)
+	printSUTLog(f, e)
+	addHAREntry(f, e)
+}
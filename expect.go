@@ -0,0 +1,145 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/verdverm/frisby"
+)
+
+// captureNamePattern matches names of captured variables
+var captureNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z_0-9]*$`)
+
+// variables captured from responses by Capture expressions, they are
+// available as vars.name in expressions of later tests
+var capturedVariables = make(map[string]interface{})
+
+// responseEnv prepares environment for evaluation of expressions against
+// response. Body that is not JSON is provided as string.
+func responseEnv(f *frisby.Frisby) (*exprEnv, error) {
+	headers := make(map[string]interface{})
+	for name, values := range f.Resp.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+
+	text, err := f.Resp.Content()
+	if err != nil {
+		return nil, err
+	}
+
+	var body interface{}
+	if json.Unmarshal(text, &body) != nil {
+		body = string(text)
+	}
+
+	vars := make(map[string]interface{}, len(capturedVariables))
+	for name, value := range capturedVariables {
+		vars[name] = value
+	}
+
+	return &exprEnv{Names: map[string]interface{}{
+		exprStatus:  float64(f.Resp.StatusCode),
+		exprHeaders: headers,
+		exprBody:    body,
+		exprVars:    vars,
+	}}, nil
+}
+
+// expectationFailure describes why expression is not true. Both sides of
+// comparison are shown as they are the most useful part of the message.
+func expectationFailure(expression string, node exprNode, env *exprEnv) string {
+	if binary, ok := node.(*binaryNode); ok && binary.Operator != "&&" && binary.Operator != "||" {
+		left, leftErr := binary.Left.Eval(env)
+		right, rightErr := binary.Right.Eval(env)
+		if leftErr == nil && rightErr == nil {
+			return fmt.Sprintf("Expected %q to be true, but left side is %s and right side is %s",
+				expression, formatJSONValue(left), formatJSONValue(right))
+		}
+	}
+	return fmt.Sprintf("Expected %q to be true", expression)
+}
+
+// checkExpectations evaluates all Expect expressions against response
+func checkExpectations(f *frisby.Frisby, test *RestAPITest) {
+	if len(test.Expect) == 0 && len(test.Capture) == 0 {
+		return
+	}
+
+	env, err := responseEnv(f)
+	if err != nil {
+		f.AddError(err.Error())
+		return
+	}
+
+	for _, expression := range test.Expect {
+		node, err := parseExpression(expression)
+		if err != nil {
+			f.AddError(fmt.Sprintf("Expression %q is improper: %v", expression, err))
+			continue
+		}
+		result, err := evalBoolean(node, env)
+		if err != nil {
+			f.AddError(fmt.Sprintf("Expression %q can't be evaluated: %v", expression, err))
+			continue
+		}
+		if !result {
+			f.AddError(expectationFailure(expression, node, env))
+		}
+	}
+
+	for _, name := range sortedKeys(test.Capture) {
+		expression := test.Capture[name]
+		node, err := parseExpression(expression)
+		if err != nil {
+			f.AddError(fmt.Sprintf("Expression %q is improper: %v", expression, err))
+			continue
+		}
+		value, err := node.Eval(env)
+		if err != nil {
+			f.AddError(fmt.Sprintf("Variable %s can't be captured: %v", name, err))
+			continue
+		}
+		capturedVariables[name] = value
+	}
+}
+
+// lintExpressions checks that all Expect and Capture expressions can be
+// parsed and that names of captured variables are proper
+func lintExpressions(test *RestAPITest) []string {
+	var problems []string
+
+	for _, expression := range test.Expect {
+		if _, err := parseExpression(expression); err != nil {
+			problems = append(problems, fmt.Sprintf("Expect expression %q is improper: %v", expression, err))
+		}
+	}
+
+	for _, name := range sortedKeys(test.Capture) {
+		if !captureNamePattern.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("Capture contains improper variable name %q", name))
+		}
+		if _, err := parseExpression(test.Capture[name]); err != nil {
+			problems = append(problems, fmt.Sprintf("Capture expression %q is improper: %v", test.Capture[name], err))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,909 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Small expression language used by assertions in test specifications, for
+// example:
+//
+//     len(body.reports) == body.meta.count
+//     all(body.reports, it.total_risk >= 1 && it.total_risk <= 4)
+//
+// Expressions are evaluated against the response. They can only read values
+// and call built-in functions, so they have no side effects and their
+// evaluation always terminates.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// names that can be used in expressions
+const (
+	exprStatus  = "status"
+	exprHeaders = "headers"
+	exprBody    = "body"
+	exprVars    = "vars"
+	exprItem    = "it"
+)
+
+// kinds of tokens
+const (
+	tokenEOF = iota
+	tokenNumber
+	tokenString
+	tokenName
+	tokenOperator
+)
+
+// operators ordered so that longer operators are matched first
+var exprOperators = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "[", "]", ".", ",",
+}
+
+// exprFunctions maps built-in functions to number of their arguments
+var exprFunctions = map[string]int{
+	"len":        1,
+	"all":        2,
+	"any":        2,
+	"count":      2,
+	"keys":       1,
+	"lower":      1,
+	"upper":      1,
+	"startsWith": 2,
+	"endsWith":   2,
+	"matches":    2,
+}
+
+// functions with predicate evaluated for each item of list in second
+// argument
+var predicateFunctions = map[string]bool{
+	"all":   true,
+	"any":   true,
+	"count": true,
+}
+
+// exprToken represents one token of expression
+type exprToken struct {
+	Kind     int
+	Text     string
+	Value    interface{}
+	Position int
+}
+
+// exprEnv contains values that can be used by expression
+type exprEnv struct {
+	Names map[string]interface{}
+
+	// item of list currently checked by predicate
+	Item    interface{}
+	HasItem bool
+}
+
+// exprNode is node of parsed expression
+type exprNode interface {
+	Eval(env *exprEnv) (interface{}, error)
+	String() string
+}
+
+// literal node represents constant value
+type literalNode struct {
+	Value interface{}
+}
+
+// name node represents value provided by environment
+type nameNode struct {
+	Name string
+}
+
+// member node represents access to field of object
+type memberNode struct {
+	Object exprNode
+	Field  string
+}
+
+// index node represents access to array item or object field
+type indexNode struct {
+	Object exprNode
+	Index  exprNode
+}
+
+// list node represents list of values
+type listNode struct {
+	Items []exprNode
+}
+
+// call node represents call of built-in function
+type callNode struct {
+	Function string
+	Args     []exprNode
+}
+
+// unary node represents operator with one operand
+type unaryNode struct {
+	Operator string
+	Operand  exprNode
+}
+
+// binary node represents operator with two operands
+type binaryNode struct {
+	Operator string
+	Left     exprNode
+	Right    exprNode
+}
+
+// tokenizeExpression splits expression into tokens
+func tokenizeExpression(source string) ([]exprToken, error) {
+	var tokens []exprToken
+
+	for i := 0; i < len(source); {
+		r, size := utf8.DecodeRuneInString(source[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case isDigit(source, i):
+			start := i
+			i = scanNumber(source, i)
+			value, err := strconv.ParseFloat(source[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("position %d: improper number %q", start+1, source[start:i])
+			}
+			tokens = append(tokens, exprToken{tokenNumber, source[start:i], value, start})
+		case r == '"' || r == '\'':
+			start := i
+			value, length, err := scanString(source[i:])
+			if err != nil {
+				return nil, fmt.Errorf("position %d: %v", start+1, err)
+			}
+			i += length
+			tokens = append(tokens, exprToken{tokenString, source[start:i], value, start})
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(source) {
+				r, size := utf8.DecodeRuneInString(source[i:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, exprToken{tokenName, source[start:i], nil, start})
+		default:
+			operator := ""
+			for _, candidate := range exprOperators {
+				if strings.HasPrefix(source[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("position %d: unexpected character %q", i+1, r)
+			}
+			tokens = append(tokens, exprToken{tokenOperator, operator, nil, i})
+			i += len(operator)
+		}
+	}
+
+	return append(tokens, exprToken{tokenEOF, "", nil, len(source)}), nil
+}
+
+// isDigit checks if there is decimal digit at given position of source
+func isDigit(source string, i int) bool {
+	return i < len(source) && source[i] >= '0' && source[i] <= '9'
+}
+
+// scanNumber returns position after number starting at given position. The
+// number can have fraction and exponent, like 1.5e-3.
+func scanNumber(source string, i int) int {
+	for isDigit(source, i) {
+		i++
+	}
+	if i < len(source) && source[i] == '.' && isDigit(source, i+1) {
+		i++
+		for isDigit(source, i) {
+			i++
+		}
+	}
+	if i < len(source) && (source[i] == 'e' || source[i] == 'E') {
+		exponent := i + 1
+		if exponent < len(source) && (source[exponent] == '+' || source[exponent] == '-') {
+			exponent++
+		}
+		if isDigit(source, exponent) {
+			i = exponent
+			for isDigit(source, i) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+// scanString reads quoted string from the beginning of source, value of
+// string and length of quoted string are returned
+func scanString(source string) (string, int, error) {
+	quote := source[0]
+	var value strings.Builder
+
+	for i := 1; i < len(source); i++ {
+		c := source[i]
+		switch {
+		case c == quote:
+			return value.String(), i + 1, nil
+		case c == '\\' && i+1 < len(source):
+			i++
+			switch source[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			default:
+				value.WriteByte(source[i])
+			}
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("string %s is not terminated", source)
+}
+
+// exprParser is recursive descent parser of expressions
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+// parseExpression parses expression. All names and functions are checked,
+// so only evaluation of values can fail later.
+func parseExpression(source string) (exprNode, error) {
+	tokens, err := tokenizeExpression(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().Kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+	return node, nil
+}
+
+// peek returns current token
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+// next returns current token and moves to the next one
+func (p *exprParser) next() exprToken {
+	token := p.tokens[p.pos]
+	if token.Kind != tokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+// accept moves to the next token if current token is given operator or
+// keyword
+func (p *exprParser) accept(text string) bool {
+	token := p.peek()
+	if (token.Kind == tokenOperator || token.Kind == tokenName) && token.Text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect moves to the next token that needs to be given operator
+func (p *exprParser) expect(text string) error {
+	if !p.accept(text) {
+		return fmt.Errorf("position %d: expected %q, but got %s", p.peek().Position+1, text, describeToken(p.peek()))
+	}
+	return nil
+}
+
+// unexpected returns error for current token
+func (p *exprParser) unexpected() error {
+	return fmt.Errorf("position %d: unexpected %s", p.peek().Position+1, describeToken(p.peek()))
+}
+
+// describeToken returns description of token suitable for error messages
+func describeToken(token exprToken) string {
+	if token.Kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(token.Text)
+}
+
+// parseBinary parses left associative binary operators with operands
+// parsed by given function
+func (p *exprParser) parseBinary(operators []string, operand func() (exprNode, error)) (exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		matched := ""
+		for _, operator := range operators {
+			if p.accept(operator) {
+				matched = operator
+				break
+			}
+		}
+		if matched == "" {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{matched, left, right}
+	}
+}
+
+// parseOr parses the whole expression
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseBinary([]string{"||"}, p.parseAnd)
+}
+
+// parseAnd parses conjunction
+func (p *exprParser) parseAnd() (exprNode, error) {
+	return p.parseBinary([]string{"&&"}, p.parseNot)
+}
+
+// parseNot parses negation
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.accept("!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{"!", operand}, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses comparison, comparisons can't be chained
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">", "in"} {
+		if p.accept(operator) {
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &binaryNode{operator, left, right}, nil
+		}
+	}
+	return left, nil
+}
+
+// parseAdditive parses addition and subtraction
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	return p.parseBinary([]string{"+", "-"}, p.parseMultiplicative)
+}
+
+// parseMultiplicative parses multiplication, division and remainder
+func (p *exprParser) parseMultiplicative() (exprNode, error) {
+	return p.parseBinary([]string{"*", "/", "%"}, p.parseUnary)
+}
+
+// parseUnary parses unary minus
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.accept("-") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{"-", operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix parses access to fields and items
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.accept("."):
+			token := p.next()
+			if token.Kind != tokenName {
+				return nil, fmt.Errorf("position %d: expected field name, but got %s",
+					token.Position+1, describeToken(token))
+			}
+			node = &memberNode{node, token.Text}
+		case p.accept("["):
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			node = &indexNode{node, index}
+		default:
+			return node, nil
+		}
+	}
+}
+
+// parseList parses comma separated expressions up to given closing operator
+func (p *exprParser) parseList(closing string) ([]exprNode, error) {
+	var items []exprNode
+	if p.accept(closing) {
+		return items, nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.accept(closing) {
+			return items, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parsePrimary parses literals, names, function calls and parenthesized
+// expressions
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	token := p.peek()
+
+	switch token.Kind {
+	case tokenNumber, tokenString:
+		p.next()
+		return &literalNode{token.Value}, nil
+	case tokenName:
+		p.next()
+		switch token.Text {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		case "null":
+			return &literalNode{nil}, nil
+		case exprStatus, exprHeaders, exprBody, exprVars, exprItem:
+			return &nameNode{token.Text}, nil
+		}
+		arity, found := exprFunctions[token.Text]
+		if !found {
+			return nil, fmt.Errorf("position %d: unknown name %q", token.Position+1, token.Text)
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		args, err := p.parseList(")")
+		if err != nil {
+			return nil, err
+		}
+		if len(args) != arity {
+			return nil, fmt.Errorf("position %d: function %s needs %d arguments, but got %d",
+				token.Position+1, token.Text, arity, len(args))
+		}
+		return &callNode{token.Text, args}, nil
+	case tokenOperator:
+		switch {
+		case p.accept("("):
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return node, nil
+		case p.accept("["):
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{items}, nil
+		}
+	}
+	return nil, p.unexpected()
+}
+
+// Eval returns constant value
+func (n *literalNode) Eval(env *exprEnv) (interface{}, error) {
+	return n.Value, nil
+}
+
+// String returns literal in source form
+func (n *literalNode) String() string {
+	return formatJSONValue(n.Value)
+}
+
+// Eval returns value provided by environment
+func (n *nameNode) Eval(env *exprEnv) (interface{}, error) {
+	if n.Name == exprItem {
+		if !env.HasItem {
+			return nil, fmt.Errorf("%s can be used only in predicate of all, any and count", exprItem)
+		}
+		return env.Item, nil
+	}
+	return env.Names[n.Name], nil
+}
+
+// String returns name
+func (n *nameNode) String() string {
+	return n.Name
+}
+
+// Eval returns value of field, null is returned for missing field and for
+// field of null
+func (n *memberNode) Eval(env *exprEnv) (interface{}, error) {
+	object, err := n.Object.Eval(env)
+	if err != nil || object == nil {
+		return nil, err
+	}
+	node, ok := object.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is %s, so it has no field %s", n.Object, jsonType(object), n.Field)
+	}
+	return node[n.Field], nil
+}
+
+// String returns access to field in source form
+func (n *memberNode) String() string {
+	return n.Object.String() + "." + n.Field
+}
+
+// Eval returns item of array or field of object
+func (n *indexNode) Eval(env *exprEnv) (interface{}, error) {
+	object, err := n.Object.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	index, err := n.Index.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node := object.(type) {
+	case nil:
+		// the same as field of null
+		return nil, nil
+	case []interface{}:
+		i, ok := index.(float64)
+		if !ok || math.IsInf(i, 0) || math.IsNaN(i) || i != math.Trunc(i) {
+			return nil, fmt.Errorf("index of %s needs to be integer, but got %s", n.Object, formatJSONValue(index))
+		}
+		// range is checked before conversion, huge numbers would overflow int
+		if i < 0 || i >= float64(len(node)) {
+			return nil, fmt.Errorf("index %s is out of range of %s with %d items", formatJSONValue(index), n.Object, len(node))
+		}
+		return node[int(i)], nil
+	case map[string]interface{}:
+		key, ok := index.(string)
+		if !ok {
+			return nil, fmt.Errorf("key of %s needs to be string, but got %s", n.Object, formatJSONValue(index))
+		}
+		return node[key], nil
+	}
+	return nil, fmt.Errorf("%s is %s, so it can't be indexed", n.Object, jsonType(object))
+}
+
+// String returns access to item in source form
+func (n *indexNode) String() string {
+	return fmt.Sprintf("%s[%s]", n.Object, n.Index)
+}
+
+// Eval returns list of values of all items
+func (n *listNode) Eval(env *exprEnv) (interface{}, error) {
+	items := make([]interface{}, 0, len(n.Items))
+	for _, item := range n.Items {
+		value, err := item.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+	}
+	return items, nil
+}
+
+// String returns list in source form
+func (n *listNode) String() string {
+	items := make([]string, 0, len(n.Items))
+	for _, item := range n.Items {
+		items = append(items, item.String())
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// Eval calls built-in function
+func (n *callNode) Eval(env *exprEnv) (interface{}, error) {
+	if predicateFunctions[n.Function] {
+		return n.evalPredicate(env)
+	}
+
+	args := make([]interface{}, 0, len(n.Args))
+	for _, arg := range n.Args {
+		value, err := arg.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+
+	switch n.Function {
+	case "len":
+		switch value := args[0].(type) {
+		case string:
+			return float64(utf8.RuneCountInString(value)), nil
+		case []interface{}:
+			return float64(len(value)), nil
+		case map[string]interface{}:
+			return float64(len(value)), nil
+		}
+		return nil, fmt.Errorf("len can't be used for %s", jsonType(args[0]))
+	case "keys":
+		object, ok := args[0].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("keys can't be used for %s", jsonType(args[0]))
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		result := make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			result = append(result, key)
+		}
+		return result, nil
+	}
+
+	// the rest of functions work with strings only
+	strs := make([]string, 0, len(args))
+	for _, arg := range args {
+		s, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s needs string arguments, but got %s", n.Function, jsonType(arg))
+		}
+		strs = append(strs, s)
+	}
+
+	switch n.Function {
+	case "lower":
+		return strings.ToLower(strs[0]), nil
+	case "upper":
+		return strings.ToUpper(strs[0]), nil
+	case "startsWith":
+		return strings.HasPrefix(strs[0], strs[1]), nil
+	case "endsWith":
+		return strings.HasSuffix(strs[0], strs[1]), nil
+	case "matches":
+		pattern, err := regexp.Compile(strs[1])
+		if err != nil {
+			return nil, err
+		}
+		return pattern.MatchString(strs[0]), nil
+	}
+	return nil, fmt.Errorf("unknown function %s", n.Function)
+}
+
+// evalPredicate evaluates predicate for all items of list
+func (n *callNode) evalPredicate(env *exprEnv) (interface{}, error) {
+	value, err := n.Args[0].Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s needs array, but %s is %s", n.Function, n.Args[0], jsonType(value))
+	}
+
+	count := 0
+	itemEnv := &exprEnv{Names: env.Names, HasItem: true}
+	for i, item := range items {
+		itemEnv.Item = item
+		result, err := evalBoolean(n.Args[1], itemEnv)
+		if err != nil {
+			return nil, fmt.Errorf("item #%d: %v", i+1, err)
+		}
+		switch {
+		case !result && n.Function == "all":
+			return false, nil
+		case result && n.Function == "any":
+			return true, nil
+		case result:
+			count++
+		}
+	}
+
+	switch n.Function {
+	case "all":
+		return true, nil
+	case "any":
+		return false, nil
+	}
+	return float64(count), nil
+}
+
+// String returns function call in source form
+func (n *callNode) String() string {
+	args := make([]string, 0, len(n.Args))
+	for _, arg := range n.Args {
+		args = append(args, arg.String())
+	}
+	return n.Function + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Eval applies unary operator
+func (n *unaryNode) Eval(env *exprEnv) (interface{}, error) {
+	if n.Operator == "!" {
+		value, err := evalBoolean(n.Operand, env)
+		return !value, err
+	}
+
+	value, err := n.Operand.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	number, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s needs to be number, but it is %s", n.Operand, jsonType(value))
+	}
+	return -number, nil
+}
+
+// String returns unary operator in source form
+func (n *unaryNode) String() string {
+	return n.Operator + n.Operand.String()
+}
+
+// Eval applies binary operator
+func (n *binaryNode) Eval(env *exprEnv) (interface{}, error) {
+	// logical operators are evaluated lazily
+	switch n.Operator {
+	case "&&", "||":
+		left, err := evalBoolean(n.Left, env)
+		if err != nil || left == (n.Operator == "||") {
+			return left, err
+		}
+		return evalBoolean(n.Right, env)
+	}
+
+	left, err := n.Left.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.Right.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Operator {
+	case "==":
+		return reflect.DeepEqual(left, right), nil
+	case "!=":
+		return !reflect.DeepEqual(left, right), nil
+	case "<", "<=", ">", ">=":
+		order, ok := compareJSONValues(left, right)
+		if !ok {
+			return nil, fmt.Errorf("%s and %s can't be compared", jsonType(left), jsonType(right))
+		}
+		switch n.Operator {
+		case "<":
+			return order < 0, nil
+		case "<=":
+			return order <= 0, nil
+		case ">":
+			return order > 0, nil
+		}
+		return order >= 0, nil
+	case "in":
+		return evalIn(left, right)
+	}
+
+	if n.Operator == "+" {
+		leftString, leftOk := left.(string)
+		rightString, rightOk := right.(string)
+		if leftOk && rightOk {
+			return leftString + rightString, nil
+		}
+	}
+
+	leftNumber, leftOk := left.(float64)
+	rightNumber, rightOk := right.(float64)
+	if !leftOk || !rightOk {
+		return nil, fmt.Errorf("operator %s can't be used for %s and %s", n.Operator, jsonType(left), jsonType(right))
+	}
+	switch n.Operator {
+	case "+":
+		return leftNumber + rightNumber, nil
+	case "-":
+		return leftNumber - rightNumber, nil
+	case "*":
+		return leftNumber * rightNumber, nil
+	}
+	if rightNumber == 0 {
+		return nil, fmt.Errorf("division by zero in %s", n)
+	}
+	if n.Operator == "/" {
+		return leftNumber / rightNumber, nil
+	}
+	return math.Mod(leftNumber, rightNumber), nil
+}
+
+// String returns binary operator in source form
+func (n *binaryNode) String() string {
+	return fmt.Sprintf("(%s %s %s)", n.Left, n.Operator, n.Right)
+}
+
+// evalIn checks if value is item of array, substring of string or key of
+// object
+func evalIn(value interface{}, container interface{}) (interface{}, error) {
+	switch node := container.(type) {
+	case []interface{}:
+		for _, item := range node {
+			if reflect.DeepEqual(item, value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case string:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s can't be searched in string", jsonType(value))
+		}
+		return strings.Contains(node, s), nil
+	case map[string]interface{}:
+		key, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s can't be key of object", jsonType(value))
+		}
+		_, found := node[key]
+		return found, nil
+	}
+	return nil, fmt.Errorf("operator in can't be used for %s", jsonType(container))
+}
+
+// evalBoolean evaluates expression that needs to have boolean value
+func evalBoolean(node exprNode, env *exprEnv) (bool, error) {
+	value, err := node.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s needs to be boolean, but it is %s", node, jsonType(value))
+	}
+	return result, nil
+}
@@ -0,0 +1,299 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// body of response used by evaluator tests
+const testBody = `{
+	"meta": {"count": 2},
+	"reports": [
+		{"rule_id": "a", "total_risk": 1, "tags": ["security"]},
+		{"rule_id": "b", "total_risk": 3, "tags": []}
+	],
+	"status": "ok"
+}`
+
+// newTestEnv constructs environment with test body, headers and variables
+func newTestEnv(t *testing.T) *exprEnv {
+	var body interface{}
+	if err := json.Unmarshal([]byte(testBody), &body); err != nil {
+		t.Fatal(err)
+	}
+	return &exprEnv{Names: map[string]interface{}{
+		exprStatus:  float64(200),
+		exprHeaders: map[string]interface{}{"content-type": "application/json; charset=utf-8"},
+		exprBody:    body,
+		exprVars:    map[string]interface{}{"rule": "b", "count": float64(2)},
+	}}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		problem    string
+	}{
+		{"", "position 1: unexpected end of expression"},
+		{"len(body) ==", "position 13: unexpected end of expression"},
+		{"body.a == 1 == 2", `position 13: unexpected "=="`},
+		{"foo(body)", `position 1: unknown name "foo"`},
+		{"response.status", `position 1: unknown name "response"`},
+		{"len(body, 1)", "position 1: function len needs 1 arguments, but got 2"},
+		{"all(body)", "position 1: function all needs 2 arguments, but got 1"},
+		{"len body", `position 5: expected "(", but got "body"`},
+		{"'unterminated", "position 1: string 'unterminated is not terminated"},
+		{"body.", "position 6: expected field name, but got end of expression"},
+		{"body.1", `position 6: expected field name, but got "1"`},
+		{"body[0", `position 7: expected "]", but got end of expression`},
+		{"(status == 200", `position 15: expected ")", but got end of expression`},
+		{"[1, 2", `position 6: expected ",", but got end of expression`},
+		{"status # 1", `position 8: unexpected character '#'`},
+		{"status == 200 body", `position 15: unexpected "body"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := parseExpression(tt.expression)
+			if err == nil || err.Error() != tt.problem {
+				t.Errorf("expected error %q, got %v", tt.problem, err)
+			}
+		})
+	}
+}
+
+func TestParseNumbers(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   float64
+	}{
+		{"42", 42},
+		{"0.25", 0.25},
+		{"1e5", 100000},
+		{"1E5", 100000},
+		{"2.5e-3", 0.0025},
+		{"1e+2", 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			node, err := parseExpression(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			value, err := node.Eval(&exprEnv{})
+			if err != nil || value != tt.expected {
+				t.Errorf("expected %v, got %v (error %v)", tt.expected, value, err)
+			}
+		})
+	}
+}
+
+func TestEvalExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   interface{}
+	}{
+		// examples from documentation
+		{"len(body.reports) == body.meta.count", true},
+		{"all(body.reports, it.total_risk >= 1 && it.total_risk <= 4)", true},
+
+		// literals and arithmetic
+		{"1 + 2 * 3", float64(7)},
+		{"(1 + 2) * 3", float64(9)},
+		{"10 / 4", 2.5},
+		{"10 % 4", float64(2)},
+		{"-status + 1", float64(-199)},
+		{"'a' + \"b\"", "ab"},
+		{`"tab\there"`, "tab\there"},
+		{"[1, 'a', null, true]", []interface{}{float64(1), "a", nil, true}},
+
+		// comparisons and logic
+		{"status == 200", true},
+		{"status != 200", false},
+		{"status < 300 && status >= 200", true},
+		{"'abc' < 'abd'", true},
+		{"!(status == 200) || false", false},
+		{"body.reports[0] == body.reports[1]", false},
+		{"body.reports[1].tags == []", true},
+
+		// field and item access
+		{"body.reports[1].rule_id", "b"},
+		{"body['meta']['count']", float64(2)},
+		{"body.reports[len(body.reports) - 1].total_risk", float64(3)},
+		{`headers["content-type"]`, "application/json; charset=utf-8"},
+		{"vars.rule", "b"},
+
+		// missing fields
+		{"body.missing", nil},
+		{"body.missing == null", true},
+		{"body.missing.x == null", true},
+		{"body.missing['x'][0]", nil},
+		{"vars.unknown", nil},
+
+		// in operator
+		{"'security' in body.reports[0].tags", true},
+		{"'json' in headers['content-type']", true},
+		{"'meta' in body", true},
+		{"'other' in body", false},
+
+		// functions
+		{"len('čau')", float64(3)},
+		{"len(body.meta)", float64(1)},
+		{"keys(body)", []interface{}{"meta", "reports", "status"}},
+		{"lower('ABC') == 'abc' && upper('abc') == 'ABC'", true},
+		{"startsWith(headers['content-type'], 'application/json')", true},
+		{"endsWith(body.status, 'k')", true},
+		{"matches(body.reports[0].rule_id, '^[a-z]$')", true},
+		{"any(body.reports, it.rule_id == vars.rule)", true},
+		{"any(body.reports, it.total_risk > 3)", false},
+		{"all(body.reports, it.total_risk > 1)", false},
+		{"all([], false)", true},
+		{"count(body.reports, it.total_risk > 2)", float64(1)},
+		{"count(body.reports, len(it.tags) == 0) == 1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			node, err := parseExpression(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			value, err := node.Eval(newTestEnv(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("expected %#v, got %#v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestEvalExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		problem    string
+	}{
+		{"body.status.x", "body.status is string, so it has no field x"},
+		{"body.reports[2]", "index 2 is out of range of body.reports with 2 items"},
+		{"body.reports[-1]", "index -1 is out of range of body.reports with 2 items"},
+		{"body.reports[1e20]", "index 100000000000000000000 is out of range of body.reports with 2 items"},
+		{"[1, 2][1e308 * 10]", "index of [1, 2] needs to be integer, but got +Inf"},
+		{"[1, 2][-1e308 * 10]", "index of [1, 2] needs to be integer, but got -Inf"},
+		{"[1, 2][1e308 * 10 - 1e308 * 10]", "index of [1, 2] needs to be integer, but got NaN"},
+		{"body.reports[0.5]", "index of body.reports needs to be integer, but got 0.5"},
+		{"body.reports['a']", `index of body.reports needs to be integer, but got "a"`},
+		{"body.meta[0]", "key of body.meta needs to be string, but got 0"},
+		{"status[0]", "status is number, so it can't be indexed"},
+		{"status / 0", "division by zero in (status / 0)"},
+		{"status % 0", "division by zero in (status % 0)"},
+		{"status + 'a'", "operator + can't be used for number and string"},
+		{"-body.status", "body.status needs to be number, but it is string"},
+		{"status < 'a'", "number and string can't be compared"},
+		{"status && true", "status needs to be boolean, but it is number"},
+		{"!status", "status needs to be boolean, but it is number"},
+		{"it.total_risk", "it can be used only in predicate of all, any and count"},
+		{"all(body.meta, true)", "all needs array, but body.meta is object"},
+		{"all(body.reports, it.total_risk)", "item #1: it.total_risk needs to be boolean, but it is number"},
+		{"len(status)", "len can't be used for number"},
+		{"keys(body.reports)", "keys can't be used for array"},
+		{"lower(status)", "lower needs string arguments, but got number"},
+		{"matches('a', '(')", "error parsing regexp: missing closing ): `(`"},
+		{"1 in status", "operator in can't be used for number"},
+		{"1 in 'abc'", "number can't be searched in string"},
+		{"1 in body", "number can't be key of object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			node, err := parseExpression(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = node.Eval(newTestEnv(t))
+			if err == nil || err.Error() != tt.problem {
+				t.Errorf("expected error %q, got %v", tt.problem, err)
+			}
+		})
+	}
+}
+
+func TestExpectationFailure(t *testing.T) {
+	tests := []struct {
+		expression string
+		message    string
+	}{
+		{"len(body.reports) == 3",
+			`Expected "len(body.reports) == 3" to be true, but left side is 2 and right side is 3`},
+		{"body.status in ['failed', 'error']",
+			`Expected "body.status in ['failed', 'error']" to be true, but left side is "ok" and right side is ["failed","error"]`},
+		{"status == 200 && false", `Expected "status == 200 && false" to be true`},
+		{"any(body.reports, it.total_risk > 3)", `Expected "any(body.reports, it.total_risk > 3)" to be true`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			node, err := parseExpression(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			message := expectationFailure(tt.expression, node, newTestEnv(t))
+			if message != tt.message {
+				t.Errorf("expected message %q, got %q", tt.message, message)
+			}
+		})
+	}
+}
+
+func TestLintExpressions(t *testing.T) {
+	tests := []struct {
+		name     string
+		test     RestAPITest
+		problems []string
+	}{
+		{"proper expressions", RestAPITest{
+			Expect:  []string{"status == 200", "all(body.reports, it.total_risk <= 4)"},
+			Capture: map[string]string{"cluster_id": "body.clusters[0]"},
+		}, nil},
+		{"improper Expect", RestAPITest{
+			Expect: []string{"status ==", "size(body) > 0"},
+		}, []string{
+			`Expect expression "status ==" is improper: position 10: unexpected end of expression`,
+			`Expect expression "size(body) > 0" is improper: position 1: unknown name "size"`,
+		}},
+		{"improper Capture", RestAPITest{
+			Capture: map[string]string{"1st": "body.id", "id": "body.", "ok": "body.id"},
+		}, []string{
+			`Capture contains improper variable name "1st"`,
+			`Capture expression "body." is improper: position 6: expected field name, but got end of expression`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := lintExpressions(&tt.test)
+			if strings.Join(problems, "\n") != strings.Join(tt.problems, "\n") {
+				t.Errorf("expected problems %q, got %q", tt.problems, problems)
+			}
+		})
+	}
+}
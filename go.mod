@@ -1,11 +1,14 @@
 module poc-table-drived-rest-api-tests
 
-go 1.13
+go 1.14
 
 require (
 	github.com/RedHatInsights/insights-operator-utils v1.22.0
 	github.com/RedHatInsights/insights-results-aggregator v1.2.3
 	github.com/RedHatInsights/insights-results-aggregator-data v1.3.3
+	github.com/andybalholm/brotli v1.1.0
+	github.com/mattn/go-sqlite3 v2.0.3+incompatible
+	github.com/rs/zerolog v1.20.0
 	github.com/verdverm/frisby v0.0.0-20170604211311-b16556248a9a
-	golang.org/x/net v0.0.0-20211216030914-fe4d6282115f // indirect
+	golang.org/x/net v0.0.0-20211216030914-fe4d6282115f
 )
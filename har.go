@@ -0,0 +1,445 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/verdverm/frisby"
+)
+
+// HAR format version and information about creator of HAR files
+const (
+	harVersion        = "1.2"
+	harCreatorName    = "poc-table-driven-rest-api-tests"
+	harCreatorVersion = "1.0"
+)
+
+// harFile contains name of HAR file to export all requests and responses to
+var harFile = flag.String("har", "",
+	"export all requests and responses made during the run into HAR 1.2 file")
+
+// HAR represents HTTP Archive 1.2 file
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog represents root object of HAR file
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator represents information about application that created HAR file
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry represents one exported HTTP request together with response
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARNameValue represents header, cookie or query parameter
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARRequest represents exported HTTP request
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARPostData represents body of exported HTTP request
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARResponse represents exported HTTP response
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARContent represents body of exported HTTP response
+type HARContent struct {
+	Size        int    `json:"size"`
+	Compression int    `json:"compression,omitempty"`
+	MimeType    string `json:"mimeType"`
+	Text        string `json:"text,omitempty"`
+	Encoding    string `json:"encoding,omitempty"`
+}
+
+// HARTimings represents time spent in individual phases of request, -1 is
+// used for phases that does not apply
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// entries recorded during the run
+var harEntries []HAREntry
+
+// harHeaders converts HTTP headers into HAR representation
+func harHeaders(header http.Header) []HARNameValue {
+	headers := []HARNameValue{}
+	for name, values := range header {
+		for _, value := range values {
+			headers = append(headers, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+// harCookies converts HTTP cookies into HAR representation
+func harCookies(cookies []*http.Cookie) []HARNameValue {
+	result := []HARNameValue{}
+	for _, cookie := range cookies {
+		result = append(result, HARNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+	return result
+}
+
+// harQueryString converts query parameters into HAR representation
+func harQueryString(u *url.URL) []HARNameValue {
+	result := []HARNameValue{}
+	for name, values := range u.Query() {
+		for _, value := range values {
+			result = append(result, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return result
+}
+
+// newHAREntry converts recorded request and response into HAR entry
+func newHAREntry(f *frisby.Frisby, e *exchange) HAREntry {
+	req := e.Request
+	timing := e.Timing
+
+	// wait time is measured from the moment the request was sent
+	wait := timing.TimeToFirstByte - timing.DNS - timing.Connect - timing.TLS
+	if wait < 0 {
+		wait = 0
+	}
+
+	entry := HAREntry{
+		StartedDateTime: e.Started.Format(time.RFC3339Nano),
+		Time:            milliseconds(timing.Total),
+		Request: HARRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     harCookies(req.Cookies()),
+			Headers:     harHeaders(req.Header),
+			QueryString: harQueryString(req.URL),
+			HeadersSize: -1,
+			BodySize:    len(e.RequestBody),
+		},
+		Response: HARResponse{
+			Cookies: []HARNameValue{},
+			Headers: []HARNameValue{},
+		},
+		Timings: HARTimings{
+			Blocked: -1,
+			DNS:     millisecondsOrNone(timing.DNS),
+			Connect: millisecondsOrNone(timing.Connect + timing.TLS),
+			Send:    0,
+			Wait:    milliseconds(wait),
+			Receive: milliseconds(timing.Total - timing.TimeToFirstByte),
+			SSL:     millisecondsOrNone(timing.TLS),
+		},
+		Comment: f.Name,
+	}
+
+	if len(e.RequestBody) > 0 {
+		entry.Request.PostData = &HARPostData{
+			MimeType: req.Header.Get(contentTypeHeader),
+			Text:     string(e.RequestBody),
+		}
+	}
+
+	// response is not available when the request failed
+	if f.Resp == nil || f.Resp.Response == nil {
+		entry.Response.HeadersSize = -1
+		entry.Response.BodySize = -1
+		return entry
+	}
+
+	resp := f.Resp.Response
+	entry.Response.Status = resp.StatusCode
+	entry.Response.StatusText = http.StatusText(resp.StatusCode)
+	entry.Response.HTTPVersion = resp.Proto
+	entry.Response.Cookies = harCookies(resp.Cookies())
+	entry.Response.Headers = harHeaders(resp.Header)
+	entry.Response.RedirectURL = resp.Header.Get("Location")
+	entry.Response.HeadersSize = -1
+	entry.Response.Content.MimeType = resp.Header.Get(contentTypeHeader)
+
+	body, err := f.Resp.Content()
+	if err != nil {
+		entry.Response.BodySize = -1
+		return entry
+	}
+	entry.Response.BodySize = e.WireBodySize
+	entry.Response.Content.Size = len(body)
+	entry.Response.Content.Compression = len(body) - e.WireBodySize
+	if utf8.Valid(body) {
+		entry.Response.Content.Text = string(body)
+	} else {
+		entry.Response.Content.Text = base64.StdEncoding.EncodeToString(body)
+		entry.Response.Content.Encoding = "base64"
+	}
+	return entry
+}
+
+// addHAREntry records request and response to be exported into HAR file
+func addHAREntry(f *frisby.Frisby, e *exchange) {
+	if *harFile == "" || e.Request == nil {
+		return
+	}
+	harEntries = append(harEntries, newHAREntry(f, e))
+}
+
+// writeHARFile exports all recorded entries into HAR file
+func writeHARFile(filename string) error {
+	har := HAR{
+		Log: HARLog{
+			Version: harVersion,
+			Creator: HARCreator{
+				Name:    harCreatorName,
+				Version: harCreatorVersion,
+			},
+			Entries: harEntries,
+		},
+	}
+	if har.Log.Entries == nil {
+		har.Log.Entries = []HAREntry{}
+	}
+
+	content, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, content, 0644)
+}
+
+// readHARFile reads HAR file
+func readHARFile(filename string) (HAR, error) {
+	var har HAR
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return har, err
+	}
+
+	err = json.Unmarshal(content, &har)
+	return har, err
+}
+
+// hostWithPort returns host of URL with explicit port, default port of
+// scheme is used when port is not set
+func hostWithPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return u.Host + ":80"
+	case "https":
+		return u.Host + ":443"
+	}
+	return u.Host
+}
+
+// sameOrigin checks if both URLs have the same scheme and host
+func sameOrigin(a *url.URL, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(hostWithPort(a), hostWithPort(b))
+}
+
+// endpointFromURL returns endpoint relative to apiURL for given URL. False
+// is returned for URLs that don't point to tested REST API.
+func endpointFromURL(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	base, err := url.Parse(apiURL)
+	if err != nil || !sameOrigin(u, base) {
+		return "", false
+	}
+
+	path := u.EscapedPath()
+	if !strings.HasPrefix(path, base.Path) && path+"/" != base.Path {
+		return "", false
+	}
+
+	endpoint := strings.TrimPrefix(path, base.Path)
+	if path+"/" == base.Path {
+		endpoint = ""
+	}
+	// Frisby always adds separator for query parameters, even when there
+	// are no parameters to add
+	if u.RawQuery != "" {
+		endpoint += "?" + strings.TrimRight(u.RawQuery, "&")
+	}
+	return endpoint, true
+}
+
+// organizationFromIdentity reads organization ID from encoded identity
+// header, zero is returned when organization can't be read
+func organizationFromIdentity(encoded string) int {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0
+	}
+
+	var identity struct {
+		Identity struct {
+			Internal struct {
+				OrgID string `json:"org_id"`
+			} `json:"internal"`
+		} `json:"identity"`
+	}
+	err = json.Unmarshal(decoded, &identity)
+	if err != nil {
+		return 0
+	}
+
+	orgID, err := strconv.Atoi(identity.Identity.Internal.OrgID)
+	if err != nil {
+		return 0
+	}
+	return orgID
+}
+
+// harEntryToTest converts one HAR entry into REST API test with expected
+// status and content type taken from recorded response
+func harEntryToTest(entry HAREntry) (RestAPITest, bool) {
+	endpoint, ok := endpointFromURL(entry.Request.URL)
+	if !ok {
+		return RestAPITest{}, false
+	}
+
+	test := RestAPITest{
+		Endpoint:       endpoint,
+		Method:         entry.Request.Method,
+		Message:        entry.Comment,
+		ExpectedStatus: entry.Response.Status,
+	}
+
+	if test.Message == "" {
+		test.Message = fmt.Sprintf("Check %s request to endpoint %q", entry.Request.Method, endpoint)
+	}
+
+	for _, header := range entry.Request.Headers {
+		if strings.EqualFold(header.Name, authHeaderName) {
+			test.AuthHeader = true
+			orgID := organizationFromIdentity(header.Value)
+			if orgID != 1 {
+				test.AuthHeaderOrganization = orgID
+			}
+		}
+	}
+
+	for _, header := range entry.Response.Headers {
+		if strings.EqualFold(header.Name, contentTypeHeader) {
+			test.ExpectedContentType = header.Value
+		}
+	}
+	if test.ExpectedContentType == None {
+		test.ExpectedContentType = entry.Response.Content.MimeType
+	}
+
+	return test, true
+}
+
+// harImportCommand implements the har-import command that converts HAR file
+// into JSON file with tests
+func harImportCommand(args []string) int {
+	flags := flag.NewFlagSet("har-import", flag.ExitOnError)
+	output := flags.String("output", "", "write tests into given file instead of standard output")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: har-import [-output tests.json] input.har")
+		return 2
+	}
+
+	har, err := readHARFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	imported := []RestAPITest{}
+	for _, entry := range har.Log.Entries {
+		test, ok := harEntryToTest(entry)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Skipping request to %s that is not part of REST API\n", entry.Request.URL)
+			continue
+		}
+		imported = append(imported, test)
+	}
+
+	err = writeTestsFile(*output, imported)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,173 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/verdverm/frisby"
+)
+
+// header value matchers used in ExpectedHeaders and ForbiddenHeaders maps
+const (
+	// HeaderPresent matches any value, only presence of header is checked
+	HeaderPresent = ""
+
+	// HeaderRegexPrefix is prefix of value that is regular expression
+	HeaderRegexPrefix = "regex:"
+)
+
+// matchHeaderValue checks if header value matches given matcher: exact
+// value, regular expression or just presence of header
+func matchHeaderValue(matcher string, value string) (bool, error) {
+	switch {
+	case matcher == HeaderPresent:
+		return true, nil
+	case strings.HasPrefix(matcher, HeaderRegexPrefix):
+		return regexp.MatchString(strings.TrimPrefix(matcher, HeaderRegexPrefix), value)
+	default:
+		return matcher == value, nil
+	}
+}
+
+// describeHeaderMatcher returns human readable description of matcher
+func describeHeaderMatcher(matcher string) string {
+	switch {
+	case matcher == HeaderPresent:
+		return "any value"
+	case strings.HasPrefix(matcher, HeaderRegexPrefix):
+		return fmt.Sprintf("value matching %q", strings.TrimPrefix(matcher, HeaderRegexPrefix))
+	default:
+		return fmt.Sprintf("value %q", matcher)
+	}
+}
+
+// checkExpectedHeaders checks that all expected headers are present in
+// response and that their values match
+func checkExpectedHeaders(f *frisby.Frisby, expected map[string]string) {
+headers:
+	for name, matcher := range expected {
+		values, found := f.Resp.Header[http.CanonicalHeaderKey(name)]
+		if !found {
+			f.AddError(fmt.Sprintf("Expected Header %q, but it was missing", name))
+			continue
+		}
+
+		matched := false
+		for _, value := range values {
+			ok, err := matchHeaderValue(matcher, value)
+			if err != nil {
+				f.AddError(fmt.Sprintf("Improper matcher for header %q: %v", name, err))
+				continue headers
+			}
+			matched = matched || ok
+		}
+		if !matched {
+			f.AddError(fmt.Sprintf("Expected Header %q to have %s, but got %q",
+				name, describeHeaderMatcher(matcher), values))
+		}
+	}
+}
+
+// checkForbiddenHeaders checks that none of forbidden headers is present in
+// response with matching value
+func checkForbiddenHeaders(f *frisby.Frisby, forbidden map[string]string) {
+headers:
+	for name, matcher := range forbidden {
+		for _, value := range f.Resp.Header[http.CanonicalHeaderKey(name)] {
+			ok, err := matchHeaderValue(matcher, value)
+			if err != nil {
+				f.AddError(fmt.Sprintf("Improper matcher for header %q: %v", name, err))
+				continue headers
+			}
+			if ok {
+				f.AddError(fmt.Sprintf("Header %q with %s is forbidden, but got %q",
+					name, describeHeaderMatcher(matcher), value))
+			}
+		}
+	}
+}
+
+// checkContentLength checks that Content-Length header, when present,
+// matches the actual length of response body
+func checkContentLength(f *frisby.Frisby) {
+	header := f.Resp.Header.Get(contentLengthHeader)
+	if header == "" {
+		return
+	}
+
+	// responses without body and compressed responses (the body is
+	// decompressed when read) can't be checked
+	if f.Method == http.MethodHead || f.Resp.StatusCode == http.StatusNoContent ||
+		f.Resp.StatusCode == http.StatusNotModified || f.Resp.Header.Get("Content-Encoding") != "" {
+		return
+	}
+
+	expected, err := strconv.Atoi(header)
+	if err != nil {
+		f.AddError(fmt.Sprintf("Improper %s header %q", contentLengthHeader, header))
+		return
+	}
+
+	body, err := f.Resp.Content()
+	if err != nil {
+		// error is reported by checkers that read the body
+		return
+	}
+
+	if len(body) != expected {
+		f.AddError(fmt.Sprintf("Header %s is %d, but body has %d bytes", contentLengthHeader, expected, len(body)))
+	}
+}
+
+// lintHeaderRegexps checks regular expressions used by header matchers from
+// given field of test
+func lintHeaderRegexps(field string, headers map[string]string) []string {
+	var problems []string
+	for name, matcher := range headers {
+		if strings.HasPrefix(matcher, HeaderRegexPrefix) {
+			_, err := regexp.Compile(strings.TrimPrefix(matcher, HeaderRegexPrefix))
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s contains improper regular expression for header %q: %v",
+					field, name, err))
+			}
+		}
+	}
+	return problems
+}
+
+// lintHeaderMatchers checks that header matchers are correct and that no
+// header is both expected and forbidden
+func lintHeaderMatchers(test *RestAPITest) []string {
+	var problems []string
+	problems = append(problems, lintHeaderRegexps("ExpectedHeaders", test.ExpectedHeaders)...)
+	problems = append(problems, lintHeaderRegexps("ForbiddenHeaders", test.ForbiddenHeaders)...)
+
+	for name := range test.ExpectedHeaders {
+		for forbidden, matcher := range test.ForbiddenHeaders {
+			if strings.EqualFold(name, forbidden) && matcher == HeaderPresent {
+				problems = append(problems, fmt.Sprintf("header %q is both expected and forbidden", name))
+			}
+		}
+	}
+
+	return problems
+}
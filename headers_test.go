@@ -0,0 +1,79 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/verdverm/frisby"
+)
+
+func TestCheckHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc")
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Accept-Encoding")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		expected  map[string]string
+		forbidden map[string]string
+		problems  []string
+	}{
+		{"present header", map[string]string{"x-request-id": HeaderPresent}, nil, nil},
+		{"exact value of one of values", map[string]string{"Vary": "Accept-Encoding"}, nil, nil},
+		{"regular expression", map[string]string{"X-Request-Id": HeaderRegexPrefix + "^[a-z]+$"}, nil, nil},
+		{"missing header", map[string]string{"X-Missing": HeaderPresent}, nil,
+			[]string{`Expected Header "X-Missing", but it was missing`}},
+		{"different value", map[string]string{"X-Request-Id": "xyz"}, nil,
+			[]string{`Expected Header "X-Request-Id" to have value "xyz", but got ["abc"]`}},
+		{"improper expected matcher", map[string]string{"Vary": HeaderRegexPrefix + "("}, nil,
+			[]string{"Improper matcher for header \"Vary\": error parsing regexp: missing closing ): `(`"}},
+		{"absent forbidden header", nil, map[string]string{"Server": HeaderPresent}, nil},
+		{"forbidden value", nil, map[string]string{"Vary": "Origin"},
+			[]string{`Header "Vary" with value "Origin" is forbidden, but got "Origin"`}},
+		{"improper forbidden matcher", nil, map[string]string{"Vary": HeaderRegexPrefix + "("},
+			[]string{"Improper matcher for header \"Vary\": error parsing regexp: missing closing ): `(`"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := frisby.Create(tt.name).Get(server.URL)
+			f.Send()
+			if f.Resp == nil || f.Resp.Response == nil {
+				t.Fatalf("no response: %v", f.Error())
+			}
+
+			checkExpectedHeaders(f, tt.expected)
+			checkForbiddenHeaders(f, tt.forbidden)
+
+			var problems []string
+			for _, err := range f.Errors() {
+				problems = append(problems, err.Error())
+			}
+			if strings.Join(problems, "\n") != strings.Join(tt.problems, "\n") {
+				t.Errorf("expected problems %q, got %q", tt.problems, problems)
+			}
+		})
+	}
+}
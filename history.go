@@ -0,0 +1,435 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite database driver
+)
+
+// default settings of test result history
+const (
+	defaultHistoryFile = "history.db"
+	defaultHistoryRuns = 10
+	unknownBuild       = "unknown"
+)
+
+// historyFile contains name of SQLite file where results of all runs are
+// stored
+var historyFile = flag.String("history", "",
+	"SQLite file to store results of this run into, failures of quarantined tests are not counted")
+
+// database schema of test result history
+var historySchema = []string{
+	`CREATE TABLE IF NOT EXISTS run (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_at TIMESTAMP NOT NULL,
+		target     VARCHAR NOT NULL,
+		build      VARCHAR NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS result (
+		run_id   INTEGER NOT NULL REFERENCES run(id),
+		test     VARCHAR NOT NULL,
+		passed   BOOLEAN NOT NULL,
+		duration INTEGER NOT NULL,
+		errors   VARCHAR NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS quarantine (
+		test           VARCHAR PRIMARY KEY,
+		quarantined_at TIMESTAMP NOT NULL
+	)`,
+}
+
+// historyEntry represents result of one test from one run
+type historyEntry struct {
+	RunID    int64
+	Build    string
+	Passed   bool
+	Duration time.Duration
+	Errors   string
+}
+
+// testHistory represents results of one test from recent runs, the oldest
+// result is first
+type testHistory struct {
+	Test        string
+	Entries     []historyEntry
+	Quarantined bool
+}
+
+// openHistory opens history database, schema is created when needed
+func openHistory(filename string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, statement := range historySchema {
+		_, err = db.Exec(statement)
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// readTargetBuild reads version and commit of the target from info endpoint.
+// The request is not a test, so it is not sent by Frisby.
+func readTargetBuild() string {
+	req, err := http.NewRequest(http.MethodGet, apiURL+"info", nil)
+	if err != nil {
+		return unknownBuild
+	}
+	req.Header.Set(authHeaderName, identityHeader(1))
+
+	client := &http.Client{}
+	if targetTransport != nil {
+		client.Transport = targetTransport
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return unknownBuild
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return unknownBuild
+	}
+
+	response := InfoResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil || response.Info["BuildVersion"] == "" {
+		return unknownBuild
+	}
+	build := response.Info["BuildVersion"]
+	if response.Info["BuildCommit"] != "" {
+		build += "/" + response.Info["BuildCommit"]
+	}
+	return build
+}
+
+// storeRun stores results of all tests performed in current run
+func storeRun(db *sql.DB, build string, started time.Time, results []testResult) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	run, err := tx.Exec("INSERT INTO run(started_at, target, build) VALUES ($1, $2, $3)", started, apiURL, build)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	runID, err := run.LastInsertId()
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	for _, result := range results {
+		if result.Skipped() {
+			continue
+		}
+		var errors []string
+		for _, err := range result.Errors {
+			errors = append(errors, err.Error())
+		}
+		var duration time.Duration
+		if result.Exchange != nil {
+			duration = result.Exchange.Timing.Total
+		}
+
+		_, err = tx.Exec("INSERT INTO result(run_id, test, passed, duration, errors) VALUES ($1, $2, $3, $4, $5)",
+			runID, testKey(&result.Test), result.Passed(), int64(duration), strings.Join(errors, "\n"))
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// quarantinedTests reads names of all quarantined tests
+func quarantinedTests(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query("SELECT test FROM quarantine")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	quarantined := make(map[string]bool)
+	for rows.Next() {
+		var test string
+		err = rows.Scan(&test)
+		if err != nil {
+			return nil, err
+		}
+		quarantined[test] = true
+	}
+	return quarantined, rows.Err()
+}
+
+// recordHistory stores results of current run into history database and
+// reports failures of quarantined tests. Keys of quarantined tests are
+// returned, their failures are not counted into exit code.
+func recordHistory(filename string, started time.Time, results []testResult) map[string]bool {
+	db, err := openHistory(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	err = storeRun(db, readTargetBuild(), started, results)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	quarantined, err := quarantinedTests(db)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+
+	for _, result := range results {
+		if quarantined[testKey(&result.Test)] && len(result.Errors) != 0 {
+			fmt.Printf("QUARANTINED  [%s] %d errors are not counted\n", result.Test.Message, len(result.Errors))
+		}
+	}
+	return quarantined
+}
+
+// readTestHistories reads results of all tests from given number of recent
+// runs
+func readTestHistories(db *sql.DB, runs int) ([]testHistory, error) {
+	quarantined, err := quarantinedTests(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT result.test, run.id, run.build, result.passed, result.duration, result.errors
+		  FROM result JOIN run ON run.id = result.run_id
+		 WHERE run.id IN (SELECT id FROM run ORDER BY id DESC LIMIT $1)
+		 ORDER BY run.id`, runs)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var histories []testHistory
+	index := make(map[string]int)
+
+	for rows.Next() {
+		var test string
+		var entry historyEntry
+		var duration int64
+
+		err = rows.Scan(&test, &entry.RunID, &entry.Build, &entry.Passed, &duration, &entry.Errors)
+		if err != nil {
+			return nil, err
+		}
+		entry.Duration = time.Duration(duration)
+
+		i, found := index[test]
+		if !found {
+			i = len(histories)
+			index[test] = i
+			histories = append(histories, testHistory{
+				Test:        test,
+				Quarantined: quarantined[test],
+			})
+		}
+		histories[i].Entries = append(histories[i].Entries, entry)
+	}
+	return histories, rows.Err()
+}
+
+// Outcomes returns outcomes of all runs as string, P for passed test and F
+// for failed one
+func (h testHistory) Outcomes() string {
+	var outcomes strings.Builder
+	for _, entry := range h.Entries {
+		if entry.Passed {
+			outcomes.WriteString("P")
+		} else {
+			outcomes.WriteString("F")
+		}
+	}
+	return outcomes.String()
+}
+
+// PassRate returns percentage of runs where the test passed
+func (h testHistory) PassRate() int {
+	passed := 0
+	for _, entry := range h.Entries {
+		if entry.Passed {
+			passed++
+		}
+	}
+	return passed * 100 / len(h.Entries)
+}
+
+// AverageDuration returns average duration of the test
+func (h testHistory) AverageDuration() time.Duration {
+	var total time.Duration
+	for _, entry := range h.Entries {
+		total += entry.Duration
+	}
+	return total / time.Duration(len(h.Entries))
+}
+
+// FlakyBuilds returns builds where the test both passed and failed. Runs
+// with unknown build are skipped, they can be performed against different
+// builds, so a regression would look like flaky test.
+func (h testHistory) FlakyBuilds() []string {
+	passed := make(map[string]bool)
+	failed := make(map[string]bool)
+	flaky := make(map[string]bool)
+	var builds []string
+
+	for _, entry := range h.Entries {
+		if entry.Build == unknownBuild {
+			continue
+		}
+		if entry.Passed {
+			passed[entry.Build] = true
+		} else {
+			failed[entry.Build] = true
+		}
+		if passed[entry.Build] && failed[entry.Build] && !flaky[entry.Build] {
+			flaky[entry.Build] = true
+			builds = append(builds, entry.Build)
+		}
+	}
+	return builds
+}
+
+// quarantineTest adds test into quarantine
+func quarantineTest(db *sql.DB, test string) error {
+	_, err := db.Exec("INSERT OR IGNORE INTO quarantine(test, quarantined_at) VALUES ($1, $2)", test, time.Now())
+	return err
+}
+
+// releaseTest removes test from quarantine
+func releaseTest(db *sql.DB, test string) error {
+	_, err := db.Exec("DELETE FROM quarantine WHERE test = $1", test)
+	return err
+}
+
+// printTestHistories prints trends of all tests together with flaky tests
+func printTestHistories(histories []testHistory, runs int) {
+	fmt.Printf("\nHistory of last %d runs (oldest first)\n", runs)
+	for _, history := range histories {
+		last := history.Entries[len(history.Entries)-1]
+		fmt.Printf("  %-*s %3d%%  avg %-12v last %-12v [%s]\n", runs, history.Outcomes(), history.PassRate(),
+			history.AverageDuration(), last.Duration, history.Test)
+	}
+
+	fmt.Println("\nFlaky tests")
+	found := false
+	for _, history := range histories {
+		builds := history.FlakyBuilds()
+		if len(builds) == 0 {
+			continue
+		}
+		found = true
+		status := ""
+		if history.Quarantined {
+			status = " (quarantined)"
+		}
+		fmt.Printf("      [%s]%s\n", history.Test, status)
+		fmt.Println("        -  outcome flips on build", strings.Join(builds, ", "))
+	}
+	if !found {
+		fmt.Println("  No flaky tests found")
+	}
+}
+
+// historyCommand implements the history command that shows trends of tests
+// from stored runs and manages quarantine of flaky tests
+func historyCommand(args []string) int {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	filename := flags.String("db", defaultHistoryFile, "SQLite file with stored results")
+	runs := flags.Int("runs", defaultHistoryRuns, "number of recent runs to show")
+	quarantine := flags.Bool("quarantine", false, "quarantine all flaky tests")
+	release := flags.String("release", "", "remove test with given ID or message from quarantine")
+	_ = flags.Parse(args)
+
+	if *runs <= 0 {
+		fmt.Fprintln(os.Stderr, "Number of runs needs to be positive")
+		return 2
+	}
+
+	db, err := openHistory(*filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	if *release != "" {
+		err = releaseTest(db, *release)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Released [%s] from quarantine\n", *release)
+	}
+
+	histories, err := readTestHistories(db, *runs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *quarantine {
+		for i, history := range histories {
+			if len(history.FlakyBuilds()) == 0 || history.Quarantined {
+				continue
+			}
+			err = quarantineTest(db, history.Test)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			histories[i].Quarantined = true
+			fmt.Printf("Quarantined [%s]\n", history.Test)
+		}
+	}
+
+	printTestHistories(histories, *runs)
+	return 0
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlakyBuilds(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []historyEntry
+		expected []string
+	}{
+		{"always passed", []historyEntry{
+			{Build: "v1", Passed: true},
+			{Build: "v1", Passed: true},
+		}, nil},
+		{"regression in new build", []historyEntry{
+			{Build: "v1", Passed: true},
+			{Build: "v2", Passed: false},
+		}, nil},
+		{"flaky build", []historyEntry{
+			{Build: "v1", Passed: true},
+			{Build: "v2", Passed: false},
+			{Build: "v2", Passed: true},
+			{Build: "v2", Passed: false},
+		}, []string{"v2"}},
+		{"unknown builds", []historyEntry{
+			{Build: unknownBuild, Passed: true},
+			{Build: unknownBuild, Passed: false},
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builds := testHistory{Entries: tt.entries}.FlakyBuilds()
+			if !reflect.DeepEqual(builds, tt.expected) {
+				t.Errorf("expected flaky builds %q, got %q", tt.expected, builds)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// name of the built-in table used in lint output
+const builtInTableName = "built-in table"
+
+// HTTP methods that are supported by Frisby
+var supportedMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodHead,
+	http.MethodOptions,
+}
+
+// lintProblem represents one problem found in table with tests
+type lintProblem struct {
+	Table   string
+	Index   int
+	Message string
+	Problem string
+}
+
+// String returns problem in format suitable for printing to terminal
+func (p lintProblem) String() string {
+	return fmt.Sprintf("%s: test #%d (%q): %s", p.Table, p.Index+1, p.Message, p.Problem)
+}
+
+// isSupportedMethod checks if HTTP method can be used by tests
+func isSupportedMethod(method string) bool {
+	for _, supported := range supportedMethods {
+		if method == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// lintTest checks one test specification and returns list of problems
+func lintTest(test *RestAPITest) []string {
+	var problems []string
+
+	if test.Message == "" {
+		problems = append(problems, "Message is not set")
+	}
+
+	if !isSupportedMethod(test.Method) {
+		problems = append(problems, fmt.Sprintf("unsupported HTTP method %q", test.Method))
+	}
+
+	if strings.HasPrefix(test.Endpoint, "/") {
+		problems = append(problems, fmt.Sprintf("Endpoint %q starts with slash that produces %q",
+			test.Endpoint, apiURL+test.Endpoint))
+	}
+
+	if test.ExpectedStatus == 0 {
+		problems = append(problems, "ExpectedStatus is not set")
+	} else if http.StatusText(test.ExpectedStatus) == "" {
+		problems = append(problems, fmt.Sprintf("ExpectedStatus %d is not known HTTP status", test.ExpectedStatus))
+	}
+
+	if test.ExpectedMaxDuration < 0 {
+		problems = append(problems, fmt.Sprintf("ExpectedMaxDuration %v is negative", test.ExpectedMaxDuration))
+	}
+
+	if test.AuthHeaderOrganization != 0 && !test.AuthHeader {
+		problems = append(problems, "AuthHeaderOrganization is set, but AuthHeader is false")
+	}
+
+	if test.Checker != "" {
+		if _, found := checkers[test.Checker]; !found {
+			problems = append(problems, fmt.Sprintf("unknown checker %q", test.Checker))
+		}
+		if test.AdditionalChecker != nil {
+			problems = append(problems, "both AdditionalChecker and Checker are set")
+		}
+	}
+
+	problems = append(problems, templateProblems(test)...)
+	problems = append(problems, lintHeaderMatchers(test)...)
+	problems = append(problems, lintExpectedCookies(test)...)
+	problems = append(problems, lintTLS(test)...)
+	problems = append(problems, lintPagination(test)...)
+	problems = append(problems, lintNegotiation(test)...)
+	problems = append(problems, lintCompression(test)...)
+	problems = append(problems, lintCORS(test)...)
+	problems = append(problems, lintAuditSeverities(test)...)
+	problems = append(problems, lintExpressions(test)...)
+
+	if test.ExpectedResponseStatus != None {
+		if test.Method == http.MethodHead {
+			problems = append(problems, "ExpectedResponseStatus is set, but response to HEAD method has no body")
+		}
+		if strings.HasPrefix(test.ExpectedContentType, "text/") {
+			problems = append(problems, fmt.Sprintf("ExpectedResponseStatus is set, but ExpectedContentType is %q",
+				test.ExpectedContentType))
+		}
+	}
+
+	return problems
+}
+
+// lintTests checks all tests from one table and returns list of problems
+func lintTests(table string, tests []RestAPITest) []lintProblem {
+	var problems []lintProblem
+
+	messages := make(map[string]int)
+
+	for i := range tests {
+		test := &tests[i]
+
+		for _, problem := range lintTest(test) {
+			problems = append(problems, lintProblem{table, i, test.Message, problem})
+		}
+
+		if first, found := messages[test.Message]; found && test.Message != "" {
+			problems = append(problems, lintProblem{table, i, test.Message,
+				fmt.Sprintf("Message is the same as in test #%d", first+1)})
+		} else {
+			messages[test.Message] = i
+		}
+	}
+
+	for i, testProblems := range dependencyProblems(tests) {
+		for _, problem := range testProblems {
+			problems = append(problems, lintProblem{table, i, tests[i].Message, problem})
+		}
+	}
+
+	return problems
+}
+
+// lintCommand implements the lint command that checks the built-in table and
+// all tables stored in files given as arguments
+func lintCommand(args []string) int {
+	problems := lintTests(builtInTableName, tests)
+
+	filenames := args
+	if *testsFile != "" {
+		filenames = append([]string{*testsFile}, filenames...)
+	}
+
+	for _, filename := range filenames {
+		fileTests, err := readTestsFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			return 1
+		}
+		problems = append(problems, lintTests(filename, fileTests)...)
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) != 0 {
+		fmt.Printf("\n%d problems found\n", len(problems))
+		return 1
+	}
+	fmt.Println("No problems found")
+	return 0
+}
@@ -0,0 +1,271 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/verdverm/frisby"
+)
+
+// values of Accept header sent by generated requests
+const (
+	acceptJSON        = "application/json"
+	acceptText        = "text/plain"
+	acceptAny         = "*/*"
+	acceptUnsupported = "application/x-unsupported-type"
+	acceptMalformed   = "application/json;q=foo"
+)
+
+// generatedAcceptValues contains values of Accept header sent to every
+// endpoint with declared negotiation
+var generatedAcceptValues = []string{
+	acceptJSON,
+	acceptText,
+	acceptAny,
+	acceptUnsupported,
+	acceptMalformed,
+}
+
+// Negotiation declares how endpoint reacts to Accept header
+type Negotiation struct {
+	// Produces contains media types of all representations provided by
+	// endpoint, the response needs to use one of them that is acceptable
+	Produces []string
+
+	// Strict is set when endpoint rejects unsupported types with 406 status
+	// and malformed Accept header with 400 or 406 status. Otherwise any
+	// representation can be returned in these cases too.
+	Strict bool `json:",omitempty"`
+}
+
+// mediaRange represents one media range from Accept header
+type mediaRange struct {
+	Type    string
+	Subtype string
+	Quality float64
+}
+
+// splitMediaType splits media type into type and subtype
+func splitMediaType(mediaType string) (string, string, error) {
+	parts := strings.Split(mediaType, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("media type %q needs to have type and subtype", mediaType)
+	}
+	return parts[0], parts[1], nil
+}
+
+// parseAccept parses value of Accept header into list of media ranges
+func parseAccept(value string) ([]mediaRange, error) {
+	var ranges []mediaRange
+
+	for _, part := range strings.Split(value, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			return nil, err
+		}
+		mainType, subtype, err := splitMediaType(mediaType)
+		if err != nil {
+			return nil, err
+		}
+		if mainType == "*" && subtype != "*" {
+			return nil, fmt.Errorf("media range %q is not allowed", mediaType)
+		}
+
+		quality := 1.0
+		if q, found := params["q"]; found {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				return nil, fmt.Errorf("improper quality %q of media range %q", q, mediaType)
+			}
+		}
+		ranges = append(ranges, mediaRange{mainType, subtype, quality})
+	}
+
+	if len(ranges) == 0 {
+		return nil, errors.New("no media range")
+	}
+	return ranges, nil
+}
+
+// acceptableTypes returns media types that are acceptable according to given
+// media ranges. The most specific matching range decides about each type.
+func acceptableTypes(ranges []mediaRange, produces []string) []string {
+	var acceptable []string
+
+	for _, produced := range produces {
+		mainType, subtype, err := splitMediaType(mediaTypeOf(produced))
+		if err != nil {
+			continue
+		}
+
+		specificity := -1
+		quality := 0.0
+		for _, r := range ranges {
+			current := -1
+			switch {
+			case r.Type == mainType && r.Subtype == subtype:
+				current = 2
+			case r.Type == mainType && r.Subtype == "*":
+				current = 1
+			case r.Type == "*":
+				current = 0
+			}
+			if current > specificity {
+				specificity = current
+				quality = r.Quality
+			}
+		}
+
+		if quality > 0 {
+			acceptable = append(acceptable, produced)
+		}
+	}
+	return acceptable
+}
+
+// mediaTypeOf returns lower case media type without parameters
+func mediaTypeOf(value string) string {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(value))
+	}
+	return mediaType
+}
+
+// checkRepresentation checks that the response contains representation with
+// one of given media types
+func checkRepresentation(f *frisby.Frisby, test *RestAPITest, mediaTypes []string) {
+	f.ExpectStatus(test.ExpectedStatus)
+
+	contentType := f.Resp.Header.Get(contentTypeHeader)
+	for _, mediaType := range mediaTypes {
+		if mediaTypeOf(contentType) == mediaTypeOf(mediaType) {
+			return
+		}
+	}
+	f.AddError(fmt.Sprintf("Expected Header %q to be one of %q, but got %q",
+		contentTypeHeader, mediaTypes, contentType))
+}
+
+// checkNegotiatedResponse checks that the response contains acceptable
+// representation or that it was rejected as declared by test
+func checkNegotiatedResponse(f *frisby.Frisby, test *RestAPITest, accept string) {
+	negotiation := test.Negotiation
+	status := f.Resp.StatusCode
+
+	if status >= http.StatusInternalServerError {
+		f.AddError(fmt.Sprintf("Expected Accept header %q to be handled, but got %q", accept, f.Resp.Status))
+		return
+	}
+
+	ranges, err := parseAccept(accept)
+	if err != nil {
+		if status == http.StatusBadRequest || status == http.StatusNotAcceptable {
+			return
+		}
+		if negotiation.Strict {
+			f.AddError(fmt.Sprintf("Expected malformed Accept header to be rejected with status %d or %d, but got %q",
+				http.StatusBadRequest, http.StatusNotAcceptable, f.Resp.Status))
+			return
+		}
+		checkRepresentation(f, test, negotiation.Produces)
+		return
+	}
+
+	acceptable := acceptableTypes(ranges, negotiation.Produces)
+	if len(acceptable) == 0 {
+		if status == http.StatusNotAcceptable {
+			return
+		}
+		if negotiation.Strict {
+			f.AddError(fmt.Sprintf("Expected unacceptable type to be rejected with status %d, but got %q",
+				http.StatusNotAcceptable, f.Resp.Status))
+			return
+		}
+		checkRepresentation(f, test, negotiation.Produces)
+		return
+	}
+
+	if status == http.StatusNotAcceptable {
+		f.AddError(fmt.Sprintf("Expected representation of %q, but got %q", acceptable, f.Resp.Status))
+		return
+	}
+	checkRepresentation(f, test, acceptable)
+}
+
+// checkNegotiation sends requests with all generated Accept headers to
+// given URL and checks responses, errors found are returned
+func checkNegotiation(test *RestAPITest, endpointURL string) []error {
+	var problems []error
+
+	for _, accept := range generatedAcceptValues {
+		f := prepareRequest(test, fmt.Sprintf("%s (Accept: %s)", test.Message, accept), endpointURL)
+		f.SetHeader(acceptHeader, accept)
+
+		e := sendRequest(f)
+		if !checkRequestFailed(f) {
+			checkNegotiatedResponse(f, test, accept)
+			checkErrorPayload(f, false)
+			auditResponse(f, e, test.AuditSeverities)
+		}
+		reportTest(f, e)
+		problems = append(problems, f.Errors()...)
+	}
+	return problems
+}
+
+// lintNegotiation checks that representations of endpoint are declared
+// properly
+func lintNegotiation(test *RestAPITest) []string {
+	negotiation := test.Negotiation
+	if negotiation == nil {
+		return nil
+	}
+
+	var problems []string
+	if len(negotiation.Produces) == 0 {
+		problems = append(problems, "Negotiation needs at least one media type in Produces")
+	}
+	for _, produced := range negotiation.Produces {
+		mainType, subtype, err := splitMediaType(mediaTypeOf(produced))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Produces contains improper media type: %v", err))
+		} else if mainType == "*" || subtype == "*" {
+			problems = append(problems, fmt.Sprintf("Produces contains media range %q instead of media type", produced))
+		}
+	}
+
+	if test.ExpectedContentType != None && len(negotiation.Produces) != 0 {
+		found := false
+		for _, produced := range negotiation.Produces {
+			found = found || mediaTypeOf(produced) == mediaTypeOf(test.ExpectedContentType)
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("ExpectedContentType %q is not in Produces", test.ExpectedContentType))
+		}
+	}
+	return problems
+}
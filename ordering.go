@@ -0,0 +1,297 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/verdverm/frisby"
+)
+
+// parallelTests contains maximal number of independent tests performed
+// concurrently
+var parallelTests = flag.Int("parallel", 1,
+	"maximal number of independent tests performed concurrently")
+
+// testMutex serializes everything tests do except sending requests, because
+// Frisby and reporting are not safe for concurrent use
+var testMutex sync.Mutex
+
+// concurrentRequests is set while independent tests are performed
+// concurrently
+var concurrentRequests bool
+
+// lockReleasingTransport releases test mutex while request is being sent,
+// so other tests can continue in the meantime. It is released in transport,
+// not in request hooks, because hooks are skipped when earlier hook returns
+// response or error, and the mutex would stay unlocked then.
+type lockReleasingTransport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip performs HTTP request with test mutex unlocked
+func (t lockReleasingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	testMutex.Unlock()
+	defer testMutex.Lock()
+	return t.Base.RoundTrip(req)
+}
+
+// releaseLockWhileSending lets other tests continue while request prepared
+// in Frisby object is being sent
+func releaseLockWhileSending(f *frisby.Frisby) {
+	if concurrentRequests {
+		base := f.Req.Client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		f.Req.Client.Transport = lockReleasingTransport{Base: base}
+	}
+}
+
+// sleepUnlocked waits for given duration, other tests can continue in the
+// meantime when tests are performed concurrently
+func sleepUnlocked(d time.Duration) {
+	if concurrentRequests {
+		testMutex.Unlock()
+		defer testMutex.Lock()
+	}
+	time.Sleep(d)
+}
+
+// testKey returns key that identifies test in history and baseline, ID is
+// used when set because message can be changed
+func testKey(test *RestAPITest) string {
+	if test.ID != "" {
+		return test.ID
+	}
+	return test.Message
+}
+
+// dependencyLevels splits tests into levels, tests in one level depend only
+// on tests from previous levels. Indexes of tests that can't be ordered,
+// because they depend on each other, are returned too. Unknown dependencies
+// are ignored.
+func dependencyLevels(tests []RestAPITest) ([][]int, []int) {
+	// the first test is used when ID is not unique
+	indexes := make(map[string]int)
+	for i, test := range tests {
+		if _, found := indexes[test.ID]; test.ID != "" && !found {
+			indexes[test.ID] = i
+		}
+	}
+
+	level := make([]int, len(tests))
+	ordered := make([]bool, len(tests))
+	var levels [][]int
+
+	for remaining := len(tests); remaining > 0; {
+		var current []int
+		for i, test := range tests {
+			if ordered[i] {
+				continue
+			}
+			ready := true
+			for _, dependency := range test.DependsOn {
+				j, found := indexes[dependency]
+				if found && (!ordered[j] || level[j] == len(levels)) {
+					ready = false
+				}
+			}
+			if ready {
+				ordered[i] = true
+				level[i] = len(levels)
+				current = append(current, i)
+			}
+		}
+		if len(current) == 0 {
+			break
+		}
+		levels = append(levels, current)
+		remaining -= len(current)
+	}
+
+	var cyclic []int
+	for i := range tests {
+		if !ordered[i] {
+			cyclic = append(cyclic, i)
+		}
+	}
+	return levels, cyclic
+}
+
+// dependencyProblems checks IDs and dependencies of all tests and returns
+// list of problems for each test
+func dependencyProblems(tests []RestAPITest) [][]string {
+	problems := make([][]string, len(tests))
+
+	indexes := make(map[string]int)
+	for i, test := range tests {
+		if test.ID == "" {
+			continue
+		}
+		if first, found := indexes[test.ID]; found {
+			problems[i] = append(problems[i], fmt.Sprintf("ID %q is the same as in test #%d", test.ID, first+1))
+		} else {
+			indexes[test.ID] = i
+		}
+	}
+
+	for i, test := range tests {
+		for _, dependency := range test.DependsOn {
+			switch _, found := indexes[dependency]; {
+			case dependency == test.ID:
+				problems[i] = append(problems[i], "test depends on itself")
+			case !found:
+				problems[i] = append(problems[i], fmt.Sprintf("dependency %q is not ID of any test", dependency))
+			}
+		}
+	}
+
+	_, cyclic := dependencyLevels(tests)
+	for _, i := range cyclic {
+		if len(problems[i]) == 0 {
+			problems[i] = append(problems[i], "test is part of dependency cycle")
+		}
+	}
+
+	return problems
+}
+
+// orderTests splits tests into levels that are performed one after another,
+// tests in one level are independent
+func orderTests(tests []RestAPITest) ([][]int, error) {
+	for i, testProblems := range dependencyProblems(tests) {
+		if len(testProblems) != 0 {
+			return nil, fmt.Errorf("test %q: %s", tests[i].Message, testProblems[0])
+		}
+	}
+	levels, _ := dependencyLevels(tests)
+	return levels, nil
+}
+
+// failedDependency returns ID of failed test the given test depends on,
+// either directly or through skipped tests
+func failedDependency(test *RestAPITest, failed map[string]string) string {
+	for _, dependency := range test.DependsOn {
+		if cause, found := failed[dependency]; found {
+			return cause
+		}
+	}
+	return ""
+}
+
+// performTests performs independent tests, up to given number of them
+// concurrently. Results are returned in the same order as tests.
+func performTests(tests []RestAPITest, parallel int) []testResult {
+	results := make([]testResult, len(tests))
+
+	if parallel <= 1 || len(tests) <= 1 {
+		for i := range tests {
+			results[i] = checkEndPoint(&tests[i])
+		}
+		return results
+	}
+
+	concurrentRequests = true
+	defer func() {
+		concurrentRequests = false
+	}()
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+
+	for i := range tests {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			testMutex.Lock()
+			results[i] = checkEndPoint(&tests[i])
+			testMutex.Unlock()
+			<-slots
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// runOrderedTests performs all tests in order given by their dependencies.
+// Tests depending on failed test are skipped.
+func runOrderedTests(tests []RestAPITest) ([]testResult, error) {
+	levels, err := orderTests(tests)
+	if err != nil {
+		return nil, err
+	}
+
+	// IDs of failed and skipped tests mapped to ID of failed test
+	failed := make(map[string]string)
+	var results []testResult
+
+	for _, level := range levels {
+		var runnable []RestAPITest
+		for _, i := range level {
+			test := tests[i]
+			cause := failedDependency(&test, failed)
+			if cause == "" {
+				runnable = append(runnable, test)
+				continue
+			}
+			result := testResult{
+				Test:       test,
+				SkipReason: fmt.Sprintf("skipped because %s failed", cause),
+			}
+			fmt.Printf("SKIP  [%s]\n        -  %s\n", test.Message, result.SkipReason)
+			if test.ID != "" {
+				failed[test.ID] = cause
+			}
+			results = append(results, result)
+		}
+
+		for _, result := range performTests(runnable, *parallelTests) {
+			if !result.Passed() && result.Test.ID != "" {
+				failed[result.Test.ID] = result.Test.ID
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// printSkippedReport prints all tests skipped because their dependency
+// failed
+func printSkippedReport(results []testResult) {
+	var skipped []testResult
+	for _, result := range results {
+		if result.Skipped() {
+			skipped = append(skipped, result)
+		}
+	}
+	if len(skipped) == 0 {
+		return
+	}
+
+	fmt.Println("\nSkipped")
+	fmt.Printf("  SKIPPED  [%d/%d]\n", len(skipped), len(results))
+	for _, result := range skipped {
+		fmt.Printf("      [%s]\n", result.Test.Message)
+		fmt.Println("        - ", result.SkipReason)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/verdverm/frisby"
+)
+
+// failingHook refuses to send any request
+type failingHook struct{}
+
+// BeforeRequest is called before the request is sent
+func (failingHook) BeforeRequest(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("request refused")
+}
+
+// AfterRequest is called after the response is received
+func (failingHook) AfterRequest(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	return nil, nil
+}
+
+func TestReleaseLockWhileSending(t *testing.T) {
+	saved := concurrentRequests
+	concurrentRequests = true
+	defer func() {
+		concurrentRequests = saved
+	}()
+
+	// the handler can lock the mutex only when the client released it
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testMutex.Lock()
+		testMutex.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		refused bool
+	}{
+		{"request sent", false},
+		{"request refused by hook", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testMutex.Lock()
+			// the test crashes with unlock of unlocked mutex when the lock
+			// is not held after the request
+			defer testMutex.Unlock()
+
+			f := frisby.Create(tt.name).Get(server.URL)
+			if tt.refused {
+				f.Req.Hooks = append(f.Req.Hooks, failingHook{})
+			}
+			releaseLockWhileSending(f)
+			f.Send()
+
+			if !tt.refused && (f.Resp == nil || f.Resp.Response == nil) {
+				t.Errorf("no response: %v", f.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,383 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/verdverm/frisby"
+)
+
+// supported styles of pagination
+const (
+	PaginationOffset = "offset"
+	PaginationCursor = "cursor"
+	PaginationLink   = "link"
+)
+
+// default settings of pagination
+const (
+	defaultOffsetParam = "offset"
+	defaultLimitParam  = "limit"
+	defaultCursorParam = "cursor"
+	defaultMaxPages    = 100
+)
+
+// Pagination describes how list returned by endpoint is split into pages.
+// All fields of response are specified by dot separated JSON path.
+type Pagination struct {
+	// Style is PaginationOffset, PaginationCursor or PaginationLink
+	Style string
+
+	// OffsetParam and LimitParam are names of query parameters used by
+	// offset style, PageSize is used as limit
+	OffsetParam string `json:",omitempty"`
+	LimitParam  string `json:",omitempty"`
+	PageSize    int    `json:",omitempty"`
+
+	// CursorParam is name of query parameter used by cursor style,
+	// CursorField contains cursor of next page
+	CursorParam string `json:",omitempty"`
+	CursorField string `json:",omitempty"`
+
+	// ItemsField contains items on each page
+	ItemsField string
+
+	// aggregate assertions: TotalField contains number of items reported by
+	// the first page, IDField (relative to item) needs to be unique and
+	// items need to be ordered by OrderBy field
+	TotalField string `json:",omitempty"`
+	IDField    string `json:",omitempty"`
+	OrderBy    string `json:",omitempty"`
+	Descending bool   `json:",omitempty"`
+
+	// MaxPages limits number of fetched pages, defaultMaxPages is used when
+	// not set
+	MaxPages int `json:",omitempty"`
+}
+
+// jsonPathLookup finds value on given dot separated path in decoded JSON
+// value, empty path is the root
+func jsonPathLookup(value interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return value, true
+	}
+
+	for _, segment := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			item, found := node[segment]
+			if !found {
+				return nil, false
+			}
+			value = item
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			value = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// withQueryParam returns URL with query parameter set to given value
+func withQueryParam(rawURL string, name string, value string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set(name, value)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// nextLink returns URL with rel="next" from Link header, it is resolved
+// against URL of current page
+func nextLink(header http.Header, current string) (string, error) {
+	for _, value := range header["Link"] {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				param = strings.Replace(strings.TrimSpace(param), " ", "", -1)
+				if param != `rel="next"` && param != "rel=next" {
+					continue
+				}
+				base, err := url.Parse(current)
+				if err != nil {
+					return "", err
+				}
+				next, err := base.Parse(strings.Trim(target, "<>"))
+				if err != nil {
+					return "", err
+				}
+				return next.String(), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// firstPageURL returns URL of the first page of list returned from given
+// URL
+func firstPageURL(test *RestAPITest, first string) (string, error) {
+	pagination := test.Pagination
+
+	if pagination.Style != PaginationOffset {
+		return first, nil
+	}
+
+	first, err := withQueryParam(first, paramName(pagination.OffsetParam, defaultOffsetParam), "0")
+	if err != nil {
+		return "", err
+	}
+	return withQueryParam(first, paramName(pagination.LimitParam, defaultLimitParam), strconv.Itoa(pagination.PageSize))
+}
+
+// paramName returns name of query parameter, default name is used when not
+// set
+func paramName(name string, defaultName string) string {
+	if name == "" {
+		return defaultName
+	}
+	return name
+}
+
+// nextPageURL returns URL of next page, empty string is returned for the
+// last page
+func nextPageURL(pagination *Pagination, current string, resp *http.Response,
+	body interface{}, offset int, items int) (string, error) {
+	switch pagination.Style {
+	case PaginationOffset:
+		if items < pagination.PageSize || items == 0 {
+			return "", nil
+		}
+		return withQueryParam(current, paramName(pagination.OffsetParam, defaultOffsetParam), strconv.Itoa(offset))
+	case PaginationCursor:
+		cursor, found := jsonPathLookup(body, pagination.CursorField)
+		if !found || cursor == nil || cursor == "" {
+			return "", nil
+		}
+		value, ok := cursor.(string)
+		if !ok {
+			value = formatJSONValue(cursor)
+		}
+		return withQueryParam(current, paramName(pagination.CursorParam, defaultCursorParam), value)
+	case PaginationLink:
+		return nextLink(resp.Header, current)
+	default:
+		return "", fmt.Errorf("unknown pagination style %q", pagination.Style)
+	}
+}
+
+// compareJSONValues compares two values of the same JSON type, numbers are
+// compared numerically and strings lexically
+func compareJSONValues(a interface{}, b interface{}) (int, bool) {
+	switch aValue := a.(type) {
+	case float64:
+		bValue, ok := b.(float64)
+		switch {
+		case !ok:
+			return 0, false
+		case aValue < bValue:
+			return -1, true
+		case aValue > bValue:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		bValue, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(aValue, bValue), true
+	}
+	return 0, false
+}
+
+// aggregateProblems checks items collected from all pages
+func aggregateProblems(pagination *Pagination, items []interface{}, total interface{}) []string {
+	var problems []string
+
+	if pagination.TotalField != "" {
+		count, ok := total.(float64)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("field %s with total count is missing or it is not a number",
+				pagination.TotalField))
+		} else if int(count) != len(items) {
+			problems = append(problems, fmt.Sprintf("total count is %d, but %d items were returned by all pages",
+				int(count), len(items)))
+		}
+	}
+
+	if pagination.IDField != "" {
+		seen := make(map[string]int)
+		for i, item := range items {
+			id, found := jsonPathLookup(item, pagination.IDField)
+			if !found {
+				problems = append(problems, fmt.Sprintf("item #%d has no field %s", i+1, pagination.IDField))
+				continue
+			}
+			key := formatJSONValue(id)
+			if first, duplicated := seen[key]; duplicated {
+				problems = append(problems, fmt.Sprintf("item #%d has the same ID %s as item #%d", i+1, key, first+1))
+			} else {
+				seen[key] = i
+			}
+		}
+	}
+
+	if pagination.OrderBy != "" {
+		for i := 1; i < len(items); i++ {
+			previous, _ := jsonPathLookup(items[i-1], pagination.OrderBy)
+			current, _ := jsonPathLookup(items[i], pagination.OrderBy)
+			order, ok := compareJSONValues(previous, current)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("items #%d and #%d can't be compared by field %s",
+					i, i+1, pagination.OrderBy))
+				break
+			}
+			if (!pagination.Descending && order > 0) || (pagination.Descending && order < 0) {
+				problems = append(problems, fmt.Sprintf("items are not ordered by %s: item #%d %s is after item #%d %s",
+					pagination.OrderBy, i+1, formatJSONValue(current), i, formatJSONValue(previous)))
+				break
+			}
+		}
+	}
+
+	return problems
+}
+
+// checkAllPages fetches all pages of paginated list from given URL. Every page is checked
+// as specified by test, items from all pages are checked together then.
+func checkAllPages(test *RestAPITest, endpointURL string) testResult {
+	pagination := test.Pagination
+	maxPages := pagination.MaxPages
+	if maxPages == 0 {
+		maxPages = defaultMaxPages
+	}
+
+	result := testResult{
+		Test: *test,
+	}
+	var problems []string
+	var items []interface{}
+	var total interface{}
+
+	next, err := firstPageURL(test, endpointURL)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	for page := 1; next != ""; page++ {
+		if page > maxPages {
+			problems = append(problems, fmt.Sprintf("list has more than %d pages", maxPages))
+			break
+		}
+
+		pageResult := checkRequest(test, fmt.Sprintf("%s (page %d)", test.Message, page), next)
+		result.Errors = append(result.Errors, pageResult.Errors...)
+		result.Exchange = pageResult.Exchange
+		if !pageResult.Passed() {
+			// the rest of list can't be fetched reliably
+			break
+		}
+
+		var body interface{}
+		err := json.Unmarshal(pageResult.Exchange.ResponseBody, &body)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("page %d is not JSON: %v", page, err))
+			break
+		}
+		if page == 1 {
+			total, _ = jsonPathLookup(body, pagination.TotalField)
+		}
+
+		value, _ := jsonPathLookup(body, pagination.ItemsField)
+		pageItems, ok := value.([]interface{})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("page %d: field %s with items is missing or it is not an array",
+				page, displayJSONPath(pagination.ItemsField)))
+			break
+		}
+		items = append(items, pageItems...)
+
+		next, err = nextPageURL(pagination, next, pageResult.Exchange.Response, body, len(items), len(pageItems))
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) == 0 && len(result.Errors) == 0 {
+		problems = aggregateProblems(pagination, items, total)
+	}
+
+	// aggregate assertions are reported as separate test
+	f := frisby.Create(fmt.Sprintf("%s (all pages)", test.Message))
+	for _, problem := range problems {
+		f.AddError(problem)
+		result.Errors = append(result.Errors, errors.New(problem))
+	}
+	f.PrintReport()
+
+	return result
+}
+
+// lintPagination checks that pagination is specified properly
+func lintPagination(test *RestAPITest) []string {
+	pagination := test.Pagination
+	if pagination == nil {
+		return nil
+	}
+
+	var problems []string
+	switch pagination.Style {
+	case PaginationOffset:
+		if pagination.PageSize <= 0 {
+			problems = append(problems, "offset pagination needs positive PageSize")
+		}
+	case PaginationCursor:
+		if pagination.CursorField == "" {
+			problems = append(problems, "cursor pagination needs CursorField")
+		}
+	case PaginationLink:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown pagination style %q", pagination.Style))
+	}
+
+	if pagination.MaxPages < 0 {
+		problems = append(problems, "MaxPages is negative")
+	}
+	if test.Method != "" && test.Method != http.MethodGet {
+		problems = append(problems, "only lists returned by GET method can be paginated")
+	}
+	return problems
+}
@@ -0,0 +1,277 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/verdverm/frisby"
+)
+
+// kinds of generated security probes
+const (
+	probeEncodedTraversal = "encoded traversal"
+	probeDoubleEncoded    = "double-encoded traversal"
+	probeOverlongPath     = "overlong path"
+	probeNullByte         = "null byte"
+	probeUnicodeTrick     = "unicode normalization"
+	probeTrailingSlash    = "trailing slash"
+)
+
+// length of path segment used by overlong path probes
+const overlongSegmentLength = 4096
+
+// runSecurityProbes enables path traversal and malformed URL probes
+var runSecurityProbes = flag.Bool("probes", false,
+	"run generated path traversal and malformed URL probes for all endpoints")
+
+// securityProbe represents one generated request that must not succeed
+type securityProbe struct {
+	Kind     string
+	Endpoint string
+}
+
+// securityFinding represents one problem found by security probe
+type securityFinding struct {
+	Kind     string
+	Endpoint string
+	Problem  string
+}
+
+// findings collected by all security probes that were run
+var securityFindings []securityFinding
+
+// encoded variants of traversal sequences appended to or prepended before
+// tested endpoint
+var encodedTraversalSequences = []string{
+	"%2e%2e",
+	"%2e%2e/",
+	"%2e%2e%2f",
+	"..%2f",
+	"%2e%2e%5c",
+	"..%5c",
+	"..;/",
+}
+
+// double-encoded variants of traversal sequences
+var doubleEncodedTraversalSequences = []string{
+	"%252e%252e",
+	"%252e%252e%252f",
+	"..%252f",
+	"%25252e%25252e%25252f",
+}
+
+// null byte injections
+var nullByteSequences = []string{
+	"%00",
+	"%00.json",
+	"%00/..",
+}
+
+// sequences that might be turned into dots or slashes by Unicode
+// normalization or by sloppy UTF-8 decoders (overlong encoding)
+var unicodeTrickSequences = []string{
+	"%c0%ae%c0%ae/",
+	"%c0%ae%c0%ae%c0%af",
+	"%e0%80%ae%e0%80%ae/",
+	"%ef%bc%8e%ef%bc%8e/",
+	"%ef%bc%8e%ef%bc%8e%ef%bc%8f",
+	"%e2%80%a5/",
+	"..%e2%88%95",
+}
+
+// trailing slash variants of endpoint
+var trailingSlashSequences = []string{
+	"/",
+	"//",
+	"/.",
+	"/./",
+	"/%2f",
+}
+
+// patterns that indicate that response body contains stack trace or other
+// internal details
+var stackTracePatterns = []string{
+	"goroutine ",
+	"panic:",
+	"runtime error",
+	"runtime/debug",
+	".go:",
+	"Traceback (most recent call last)",
+	"Exception in thread",
+	"\tat ",
+}
+
+// appendToEndpoint appends given sequence to endpoint so the result is still
+// relative to apiURL
+func appendToEndpoint(endpoint string, sequence string) string {
+	if endpoint == "" || strings.HasSuffix(endpoint, "/") || strings.HasPrefix(sequence, "/") {
+		return endpoint + sequence
+	}
+	return endpoint + "/" + sequence
+}
+
+// generateProbes generates all security probes for given endpoint
+func generateProbes(endpoint string) []securityProbe {
+	var probes []securityProbe
+
+	add := func(kind string, endpoint string) {
+		probes = append(probes, securityProbe{Kind: kind, Endpoint: endpoint})
+	}
+
+	for _, sequence := range encodedTraversalSequences {
+		add(probeEncodedTraversal, appendToEndpoint(endpoint, sequence))
+		add(probeEncodedTraversal, sequence+endpoint)
+	}
+
+	for _, sequence := range doubleEncodedTraversalSequences {
+		add(probeDoubleEncoded, appendToEndpoint(endpoint, sequence))
+		add(probeDoubleEncoded, sequence+endpoint)
+	}
+
+	add(probeOverlongPath, appendToEndpoint(endpoint, strings.Repeat("a", overlongSegmentLength)))
+	add(probeOverlongPath, appendToEndpoint(endpoint, strings.Repeat("../", overlongSegmentLength/3)))
+
+	for _, sequence := range nullByteSequences {
+		add(probeNullByte, endpoint+sequence)
+	}
+
+	for _, sequence := range unicodeTrickSequences {
+		add(probeUnicodeTrick, appendToEndpoint(endpoint, sequence))
+	}
+
+	// trailing slash variants of entry point are regular requests
+	if endpoint != "" {
+		for _, sequence := range trailingSlashSequences {
+			add(probeTrailingSlash, endpoint+sequence)
+		}
+	}
+
+	return probes
+}
+
+// probedEndpoints returns list of unique endpoints used by tests, in the
+// order they were found
+func probedEndpoints(tests []RestAPITest) []string {
+	var endpoints []string
+	seen := make(map[string]bool)
+
+	for _, test := range tests {
+		// tests with improper template are reported by themselves
+		endpoint, err := expandPath(&test)
+		if err == nil && !seen[endpoint] {
+			seen[endpoint] = true
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// addSecurityFinding records a finding and marks the probe as failed
+func addSecurityFinding(f *frisby.Frisby, probe securityProbe, problem string) {
+	securityFindings = append(securityFindings, securityFinding{
+		Kind:     probe.Kind,
+		Endpoint: probe.Endpoint,
+		Problem:  problem,
+	})
+	f.AddError(problem)
+}
+
+// checkProbe performs one security probe and check that the request was
+// rejected without leaking internal details. Number of errors found is
+// returned.
+func checkProbe(probe securityProbe) int {
+	name := fmt.Sprintf("Security probe (%s) %q", probe.Kind, probe.Endpoint)
+	f := frisby.Create(name)
+	f.Method = http.MethodGet
+	f.Url = apiURL + probe.Endpoint
+	setAuthHeader(f)
+
+	// perform the request
+	e := sendRequest(f)
+
+	// request that can't be performed at all is not a finding
+	if f.Resp == nil || f.Resp.Response == nil {
+		reportTest(f, e)
+		return len(f.Errors())
+	}
+
+	if f.Resp.StatusCode >= 200 && f.Resp.StatusCode < 300 {
+		addSecurityFinding(f, probe,
+			fmt.Sprintf("Expected request to be rejected, but got %q", f.Resp.Status))
+	}
+
+	text, err := f.Resp.Text()
+	if err != nil {
+		f.AddError(err.Error())
+	} else {
+		for _, pattern := range stackTracePatterns {
+			if strings.Contains(text, pattern) {
+				addSecurityFinding(f, probe,
+					fmt.Sprintf("Response body seems to contain stack trace (found %q)", pattern))
+				break
+			}
+		}
+	}
+
+	checkErrorPayload(f, false)
+	auditResponse(f, e, nil)
+	reportTest(f, e)
+	return len(f.Errors())
+}
+
+// runAllProbes generates and performs security probes for all endpoints used
+// by tests. Number of errors found by all probes is returned.
+func runAllProbes(tests []RestAPITest) int {
+	errors := 0
+	for _, endpoint := range probedEndpoints(tests) {
+		for _, probe := range generateProbes(endpoint) {
+			errors += checkProbe(probe)
+		}
+	}
+	return errors
+}
+
+// printSecurityReport prints all findings found by security probes grouped
+// by probe kind
+func printSecurityReport() {
+	fmt.Println("\nSecurity")
+	if len(securityFindings) == 0 {
+		fmt.Println("  No findings")
+		return
+	}
+
+	var kinds []string
+	grouped := make(map[string][]securityFinding)
+	for _, finding := range securityFindings {
+		if _, found := grouped[finding.Kind]; !found {
+			kinds = append(kinds, finding.Kind)
+		}
+		grouped[finding.Kind] = append(grouped[finding.Kind], finding)
+	}
+
+	fmt.Printf("  FINDINGS  [%d]\n", len(securityFindings))
+	for _, kind := range kinds {
+		fmt.Printf("      [%s]\n", kind)
+		for _, finding := range grouped[kind] {
+			fmt.Printf("        -  %q: %s\n", finding.Endpoint, finding.Problem)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// default settings of capture proxy
+const (
+	defaultCaptureAddress = "localhost:8081"
+	defaultCaptureOutput  = "captured_tests.json"
+)
+
+// proxyURL contains URL of HTTP proxy used to connect to the target
+var proxyURL = flag.String("proxy", "",
+	"send all requests through given HTTP proxy (HTTP_PROXY environment variable is used otherwise)")
+
+// configureProxy sets HTTP proxy to be used by transport
+func configureProxy(transport *http.Transport, proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("improper proxy URL %q", proxy)
+	}
+	transport.Proxy = http.ProxyURL(u)
+	return nil
+}
+
+// captureProxy is local proxy that forwards requests to the target and
+// records them as draft tests
+type captureProxy struct {
+	target *url.URL
+	output string
+	proxy  *httputil.ReverseProxy
+
+	mutex  sync.Mutex
+	drafts []RestAPITest
+}
+
+// newCaptureProxy constructs capture proxy that forwards requests to given
+// target and stores captured tests into output file
+func newCaptureProxy(target *url.URL, output string, transport http.RoundTripper) *captureProxy {
+	c := &captureProxy{
+		target: target,
+		output: output,
+		drafts: []RestAPITest{},
+	}
+
+	c.proxy = &httputil.ReverseProxy{
+		Director:       c.direct,
+		Transport:      transport,
+		ModifyResponse: c.capture,
+	}
+	return c
+}
+
+// direct rewrites incoming request to be forwarded. Requests with absolute
+// URL come from clients using this process as HTTP proxy, only requests to
+// the target are accepted by ServeHTTP. Other requests are forwarded to the
+// target.
+func (c *captureProxy) direct(req *http.Request) {
+	if !req.URL.IsAbs() {
+		req.URL.Scheme = c.target.Scheme
+		req.URL.Host = c.target.Host
+		req.Host = c.target.Host
+	}
+}
+
+// capture records response together with request as draft test
+func (c *captureProxy) capture(resp *http.Response) error {
+	req := resp.Request
+
+	entry := HAREntry{
+		Request: HARRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: harHeaders(req.Header),
+		},
+		Response: HARResponse{
+			Status:  resp.StatusCode,
+			Headers: harHeaders(resp.Header),
+		},
+	}
+
+	test, ok := harEntryToTest(entry)
+	if !ok {
+		log.Printf("Not capturing %s %s that is not part of REST API", req.Method, req.URL)
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.drafts = append(c.drafts, test)
+	log.Printf("Captured %s %s (%d)", req.Method, req.URL, resp.StatusCode)
+
+	// drafts are written after every request so nothing is lost when the
+	// proxy is interrupted
+	err := writeTestsFile(c.output, c.drafts)
+	if err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+// ServeHTTP handles one request from client
+func (c *captureProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodConnect {
+		http.Error(w, "HTTPS tunnels can't be captured, point client directly to the capture proxy instead",
+			http.StatusMethodNotAllowed)
+		return
+	}
+	// transport presents client certificate of the target, so it can't be
+	// used to connect anywhere else
+	if req.URL.IsAbs() && !sameOrigin(req.URL, c.target) {
+		http.Error(w, fmt.Sprintf("only requests to %s://%s are forwarded", c.target.Scheme, c.target.Host),
+			http.StatusForbidden)
+		return
+	}
+	c.proxy.ServeHTTP(w, req)
+}
+
+// captureCommand implements the capture command that starts local proxy
+// recording the traffic as draft tests
+func captureCommand(args []string) int {
+	flags := flag.NewFlagSet("capture", flag.ExitOnError)
+	address := flags.String("listen", defaultCaptureAddress, "address the capture proxy listens on")
+	output := flags.String("output", defaultCaptureOutput, "file to write captured draft tests into")
+	_ = flags.Parse(args)
+
+	target, err := url.Parse(apiURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	err = setupTargetTransport()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	log.Printf("Capture proxy for %s listening on %s, drafts are written into %s", apiURL, *address, *output)
+	err = http.ListenAndServe(*address, newCaptureProxy(target, *output, targetTransport))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
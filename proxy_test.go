@@ -0,0 +1,147 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEndpointFromURL(t *testing.T) {
+	savedURL := apiURL
+	defer func() {
+		apiURL = savedURL
+	}()
+	apiURL = "http://localhost:8080/api/v1/"
+
+	tests := []struct {
+		url      string
+		endpoint string
+		ok       bool
+	}{
+		{"http://localhost:8080/api/v1/info", "info", true},
+		{"http://localhost:8080/api/v1", "", true},
+		{"http://LOCALHOST:8080/api/v1/report/1?x=1&", "report/1?x=1", true},
+		{"http://localhost:8080/other", "", false},
+		{"http://localhost:9090/api/v1/info", "", false},
+		{"https://localhost:8080/api/v1/info", "", false},
+		{"http://example.com/api/v1/info", "", false},
+		{"/api/v1/info", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			endpoint, ok := endpointFromURL(tt.url)
+			if endpoint != tt.endpoint || ok != tt.ok {
+				t.Errorf("expected %q, %v, got %q, %v", tt.endpoint, tt.ok, endpoint, ok)
+			}
+		})
+	}
+}
+
+func TestSameOriginDefaultPorts(t *testing.T) {
+	a, _ := url.Parse("https://example.com/api")
+	b, _ := url.Parse("https://example.com:443/other")
+	c, _ := url.Parse("http://example.com:443/api")
+	if !sameOrigin(a, b) {
+		t.Errorf("expected %v and %v to have the same origin", a, b)
+	}
+	if sameOrigin(a, c) {
+		t.Errorf("expected %v and %v to have different origins", a, c)
+	}
+}
+
+func TestCaptureProxyForwardsToTargetOnly(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentTypeHeader, ContentTypeJSON)
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer target.Close()
+
+	savedURL := apiURL
+	defer func() {
+		apiURL = savedURL
+	}()
+	apiURL = target.URL + "/api/v1/"
+	targetURL, _ := url.Parse(apiURL)
+
+	dir, err := ioutil.TempDir("", "capture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	// captured requests are logged
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	proxy := newCaptureProxy(targetURL, filepath.Join(dir, "captured.json"), http.DefaultTransport)
+	front := httptest.NewServer(proxy)
+	defer front.Close()
+	frontURL, _ := url.Parse(front.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(frontURL)}}
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+	}{
+		{"request to target through proxy", target.URL + "/api/v1/info", http.StatusOK},
+		{"request to another host", "http://example.com/api/v1/info", http.StatusForbidden},
+		{"request to another port", "http://" + frontURL.Host + "/api/v1/info", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Get(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+
+	// requests sent directly to capture proxy are forwarded to target
+	resp, err := http.Get(front.URL + "/api/v1/info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if len(proxy.drafts) != 2 {
+		t.Fatalf("expected 2 captured tests, got %d", len(proxy.drafts))
+	}
+	for _, draft := range proxy.drafts {
+		if draft.Endpoint != "info" {
+			t.Errorf("expected captured endpoint %q, got %q", "info", draft.Endpoint)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// exit code used when the target doesn't become ready, so no test is
+// performed
+const exitCodeNotReady = 255
+
+// delay between two readiness checks
+const readinessPollInterval = 500 * time.Millisecond
+
+// readiness gate settings read from command line
+var (
+	readinessURL = flag.String("ready-url", "",
+		"URL polled before tests until the target responds, the entry point at -url is used by default")
+	readinessTimeout = flag.Duration("ready-timeout", 30*time.Second,
+		"how long to wait for the target to become ready, 0 disables the readiness gate")
+)
+
+// notReadyError is returned when the target doesn't become ready in time
+type notReadyError struct {
+	URL string
+	Err error
+}
+
+// Error implements error interface
+func (e *notReadyError) Error() string {
+	return fmt.Sprintf("target %s is not ready: %v", e.URL, e.Err)
+}
+
+// waitUntilReady polls given URL until the service responds without server
+// error. Waiting ends prematurely when process running the service exits,
+// nil channel is used when the service is not started by the runner.
+func waitUntilReady(url string, timeout time.Duration, exited <-chan struct{}) error {
+	client := http.Client{
+		Timeout: readinessPollInterval * 4,
+	}
+	if targetTransport != nil {
+		client.Transport = targetTransport
+	}
+
+	deadline := time.Now().Add(timeout)
+	var lastProblem error
+
+	for {
+		resp, err := client.Get(url)
+		if err == nil {
+			_ = resp.Body.Close()
+			if resp.StatusCode < http.StatusInternalServerError {
+				return nil
+			}
+			lastProblem = fmt.Errorf("%s responds with %s", url, resp.Status)
+		} else {
+			lastProblem = err
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout %v expired: %v", timeout, lastProblem)
+		}
+
+		select {
+		case <-exited:
+			return errors.New("process exited")
+		case <-time.After(readinessPollInterval):
+		}
+	}
+}
+
+// checkTargetReady waits until the target is ready to be tested. Tests are
+// not performed otherwise, because all of them would fail on connection
+// errors.
+func checkTargetReady() error {
+	if *readinessTimeout == 0 {
+		return nil
+	}
+
+	url := *readinessURL
+	if url == "" {
+		url = apiURL
+	}
+
+	err := waitUntilReady(url, *readinessTimeout, nil)
+	if err != nil {
+		return &notReadyError{
+			URL: url,
+			Err: err,
+		}
+	}
+	return nil
+}
@@ -17,9 +17,11 @@ limitations under the License.
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"encoding/base64"
 	"encoding/json"
@@ -28,16 +30,15 @@ import (
 
 	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
 
-	httputils "github.com/RedHatInsights/insights-operator-utils/http"
 	server "github.com/RedHatInsights/insights-results-aggregator/server"
-	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
 // common constants used by REST API tests
 const (
-	apiURL              = "http://localhost:8080/api/v1/"
+	defaultAPIURL       = "http://localhost:8080/api/v1/"
 	contentTypeHeader   = "Content-Type"
 	contentLengthHeader = "Content-Length"
+	acceptHeader        = "Accept"
 
 	authHeaderName = "x-rh-identity"
 
@@ -67,6 +68,22 @@ const (
 	None = ""
 )
 
+// apiURL contains base URL of REST API that is tested
+var apiURL = defaultAPIURL
+
+func init() {
+	flag.StringVar(&apiURL, "url", defaultAPIURL, "base URL of tested REST API (HTTP or HTTPS)")
+}
+
+// normalizeBaseURL adds trailing slash to base URL, so endpoints can be
+// appended to it
+func normalizeBaseURL(baseURL string) string {
+	if strings.HasSuffix(baseURL, "/") {
+		return baseURL
+	}
+	return baseURL + "/"
+}
+
 // states
 const (
 	OkStatusResponse = server.OkStatusPayload
@@ -85,11 +102,15 @@ var improperOrganizations = []int{-1000, -1, 0}
 // user account number
 const accountNumber = "42"
 
+// identityHeader returns value of authorization header for organization
+func identityHeader(orgID int) string {
+	plainHeader := fmt.Sprintf("{\"identity\": {\"internal\": {\"org_id\": \"%d\"}, \"account_number\":\"%s\"}}", orgID, accountNumber)
+	return base64.StdEncoding.EncodeToString([]byte(plainHeader))
+}
+
 // setAuthHeaderForOrganization set authorization header to request
 func setAuthHeaderForOrganization(f *frisby.Frisby, orgID int) {
-	plainHeader := fmt.Sprintf("{\"identity\": {\"internal\": {\"org_id\": \"%d\"}, \"account_number\":\"%s\"}}", orgID, accountNumber)
-	encodedHeader := base64.StdEncoding.EncodeToString([]byte(plainHeader))
-	f.SetHeader(authHeaderName, encodedHeader)
+	f.SetHeader(authHeaderName, identityHeader(orgID))
 }
 
 // setAuthHeader set authorization header to request for organization 1
@@ -97,23 +118,6 @@ func setAuthHeader(f *frisby.Frisby) {
 	setAuthHeaderForOrganization(f, 1)
 }
 
-// constructURLForReportForOrgCluster function constructs an URL to access the
-// endpoint to retrieve results for given cluster from selected organization
-func constructURLForReportForOrgCluster(organizationID string, clusterID string, userID types.UserID) string {
-	url := httputils.MakeURLToEndpoint("", server.ReportEndpoint, organizationID, clusterID, userID)
-	return url[1:]
-}
-
-// constructURLForReportInfoForOrgCluster function constructs an URL to access
-// the endpoint to retrieve results metadata for given cluster from selected
-// organization
-func constructURLForReportInfoForOrgCluster(organizationID string,
-	clusterID string, userID types.UserID) string {
-	url := httputils.MakeURLToEndpoint("", server.ReportMetainfoEndpoint,
-		organizationID, clusterID, userID)
-	return url[1:]
-}
-
 // readStatusFromResponse reads and parses status from response body
 func readStatusFromResponse(f *frisby.Frisby) StatusOnlyResponse {
 	response := StatusOnlyResponse{}
@@ -201,25 +205,153 @@ func infoResponseChecker(f *frisby.Frisby) {
 	}
 }
 
+// checkers contains additional checkers that can be referenced by name from
+// tests stored in files
+var checkers = map[string]func(F *frisby.Frisby){
+	"metricsEndPointContentTypeChecker": metricsEndPointContentTypeChecker,
+	"infoResponseChecker":               infoResponseChecker,
+}
+
 // RestAPITest represents specification of one REST API call (request) and
 // expected response
 type RestAPITest struct {
-	Endpoint               string
+	// ID is stable identifier of test that other tests can depend on
+	ID        string   `json:",omitempty"`
+	DependsOn []string `json:",omitempty"`
+
+	// Endpoint can be template with parameters like {org_id} that are
+	// replaced by values from PathParams, QueryParams are appended to it
+	Endpoint    string
+	PathParams  map[string]string `json:",omitempty"`
+	QueryParams map[string]string `json:",omitempty"`
+
 	Method                 string
 	Message                string
 	AuthHeader             bool
-	AuthHeaderOrganization int
+	AuthHeaderOrganization int `json:",omitempty"`
 	ExpectedStatus         int
-	ExpectedContentType    string
-	ExpectedResponseStatus string
-	AdditionalChecker      func(F *frisby.Frisby)
+	ExpectedContentType    string                 `json:",omitempty"`
+	ExpectedResponseStatus string                 `json:",omitempty"`
+	AdditionalChecker      func(F *frisby.Frisby) `json:"-"`
+	ExpectedMaxDuration    Duration               `json:",omitempty"`
+
+	// Accept is value of Accept header sent with request, Frisby sends */*
+	// when not set
+	Accept string `json:",omitempty"`
+
+	// ExpectedHeaders and ForbiddenHeaders map header names to exact
+	// value, regular expression (with HeaderRegexPrefix) or HeaderPresent
+	ExpectedHeaders  map[string]string `json:",omitempty"`
+	ForbiddenHeaders map[string]string `json:",omitempty"`
+
+	// expected parameters of TLS connection, peer subject is matched the
+	// same way as header values
+	ExpectedTLSVersion  string `json:",omitempty"`
+	ExpectedCipherSuite string `json:",omitempty"`
+	ExpectedPeerSubject string `json:",omitempty"`
+
+	// CookieJar is name of group of tests that share cookies
+	CookieJar       string           `json:",omitempty"`
+	ExpectedCookies []ExpectedCookie `json:",omitempty"`
+
+	// Checker is name of additional checker from checkers map, it is used
+	// by tests stored in files
+	Checker string `json:",omitempty"`
+
+	// Pagination is set for endpoints returning list split into pages
+	Pagination *Pagination `json:",omitempty"`
+
+	// Negotiation declares representations provided by endpoint, requests
+	// with generated Accept headers are checked when set
+	Negotiation *Negotiation `json:",omitempty"`
+
+	// Compression contains expectations about compressed response, it
+	// enables compression for the test
+	Compression *Compression `json:",omitempty"`
+
+	// CORS describes cross-origin requests, preflight requests are checked
+	// when set
+	CORS *CORS `json:",omitempty"`
+
+	// AuditSeverities maps checks of security headers audit to severity of
+	// their findings
+	AuditSeverities map[string]string `json:",omitempty"`
+
+	// Expect contains expressions evaluated against response that all need
+	// to be true, Capture maps names of variables to expressions whose
+	// values are available as vars.name in later tests
+	Expect  []string          `json:",omitempty"`
+	Capture map[string]string `json:",omitempty"`
+}
+
+// testResult represents outcome of one test together with recorded request
+// and response
+type testResult struct {
+	Test     RestAPITest
+	Errors   []error
+	Exchange *exchange
+
+	// SkipReason is set when test was not performed
+	SkipReason string
+}
+
+// Passed returns true when the test was performed and no error was found
+func (r testResult) Passed() bool {
+	return len(r.Errors) == 0 && !r.Skipped()
+}
+
+// Skipped returns true when the test was not performed
+func (r testResult) Skipped() bool {
+	return r.SkipReason != ""
+}
+
+// newTestResult constructs result of test performed by given Frisby object
+func newTestResult(test *RestAPITest, f *frisby.Frisby, e *exchange) testResult {
+	return testResult{
+		Test:     *test,
+		Errors:   f.Errors(),
+		Exchange: e,
+	}
 }
 
 // checkEndPoint performs request to selected endpoint and check the response
-func checkEndPoint(test *RestAPITest) {
-	// prepare Frisby test object
-	url := apiURL + test.Endpoint
-	f := frisby.Create(test.Message)
+func checkEndPoint(test *RestAPITest) testResult {
+	return checkEndPointAt(test, apiURL)
+}
+
+// checkEndPointAt performs request to selected endpoint of REST API on given
+// base URL and check the response
+func checkEndPointAt(test *RestAPITest, baseURL string) testResult {
+	endpoint, err := expandEndpoint(test)
+	if err != nil {
+		// improper template is reported as test error
+		f := frisby.Create(test.Message)
+		f.AddError(err.Error())
+		f.PrintReport()
+		return newTestResult(test, f, &exchange{})
+	}
+	endpointURL := baseURL + endpoint
+
+	var result testResult
+	if test.Pagination != nil {
+		result = checkAllPages(test, endpointURL)
+	} else {
+		result = checkRequest(test, test.Message, endpointURL)
+	}
+
+	if test.Negotiation != nil {
+		result.Errors = append(result.Errors, checkNegotiation(test, endpointURL)...)
+	}
+	if test.CORS != nil {
+		result.Errors = append(result.Errors, checkCORS(test, endpointURL)...)
+	}
+	return result
+}
+
+// prepareRequest prepares Frisby object with request to given URL as
+// specified by test
+func prepareRequest(test *RestAPITest, name string, url string) *frisby.Frisby {
+	f := frisby.Create(name)
 	f.Method = test.Method
 	f.Url = url
 
@@ -231,8 +363,28 @@ func checkEndPoint(test *RestAPITest) {
 		}
 	}
 
+	if test.Accept != "" {
+		f.SetHeader(acceptHeader, test.Accept)
+	}
+	requestCompression(f, test)
+
+	// cookies set by previous tests might be needed
+	useCookieJar(f, test)
+	return f
+}
+
+// checkRequest performs request to given URL and checks the response as
+// specified by test
+func checkRequest(test *RestAPITest, name string, url string) testResult {
+	// prepare Frisby test object
+	f := prepareRequest(test, name, url)
+
 	// perform the request
-	f.Send()
+	e := sendRequest(f)
+	if checkRequestFailed(f) {
+		reportTest(f, e)
+		return newTestResult(test, f, e)
+	}
 
 	// check the response
 	f.ExpectStatus(test.ExpectedStatus)
@@ -242,9 +394,23 @@ func checkEndPoint(test *RestAPITest) {
 		f.ExpectHeader(contentTypeHeader, test.ExpectedContentType)
 	}
 
+	// check other response headers
+	checkExpectedHeaders(f, test.ExpectedHeaders)
+	checkForbiddenHeaders(f, test.ForbiddenHeaders)
+	checkContentLength(f)
+	checkCompression(f, test, e)
+
+	// check cookies set by response
+	checkExpectedCookies(f, test.ExpectedCookies)
+
+	// check parameters of TLS connection
+	checkTLS(f, test)
+
 	// perform additional check, if setup
 	if test.AdditionalChecker != nil {
 		test.AdditionalChecker(f)
+	} else if checker, found := checkers[test.Checker]; found {
+		checker(f)
 	}
 
 	// status can be returned in JSON format too
@@ -252,18 +418,82 @@ func checkEndPoint(test *RestAPITest) {
 		statusResponseChecker(f, test.ExpectedResponseStatus)
 	}
 
+	// error responses need to use uniform payload, if checked
+	checkErrorPayload(f, expectsJSON(test))
+
+	// evaluate expressions against response and capture variables
+	checkExpectations(f, test)
+
+	// check how long the request took
+	checkDuration(f, test, e.Timing)
+
+	// inspect security headers, if enabled
+	auditResponse(f, e, test.AuditSeverities)
+
 	// print overall status of test to terminal
-	f.PrintReport()
+	reportTest(f, e)
+	return newTestResult(test, f, e)
 }
 
 // runAllTests function run all REST API tests provided in argument. Number of
 // errors found is returned (zero in case of no error).
 func runAllTests(tests []RestAPITest) int {
-	for _, test := range tests {
-		checkEndPoint(&test)
+	started := time.Now()
+	results, err := runOrderedTests(tests)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	probeErrors := 0
+	if *runSecurityProbes {
+		probeErrors = runAllProbes(tests)
+	}
+	if *baselineFile != "" {
+		err := compareWithBaseline(*baselineFile, results)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
 	}
 	frisby.Global.PrintReport()
-	return frisby.Global.NumErrored
+	printSkippedReport(results)
+	if *runSecurityProbes {
+		printSecurityReport()
+	}
+	if *auditSecurityHeaders {
+		printAuditReport()
+	}
+	if *baselineFile != "" {
+		printRegressionReport()
+	}
+	if *saveBaselineFile != "" {
+		err := writeBaseline(*saveBaselineFile, results)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	if *harFile != "" {
+		err := writeHARFile(*harFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	var quarantined map[string]bool
+	if *historyFile != "" {
+		quarantined = recordHistory(*historyFile, started, results)
+	}
+	return countErrors(results, quarantined) + probeErrors
+}
+
+// countErrors returns number of errors found by all tests, errors found by
+// quarantined tests are not counted
+func countErrors(results []testResult, quarantined map[string]bool) int {
+	errors := 0
+	for _, result := range results {
+		if !quarantined[testKey(&result.Test)] {
+			errors += len(result.Errors)
+		}
+	}
+	return errors
 }
 
 var tests []RestAPITest = []RestAPITest{
@@ -386,7 +616,7 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the OpenAPI endpoint",
-		Endpoint:               "/openapi.json",
+		Endpoint:               "openapi.json",
 		Method:                 http.MethodGet,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusOK,
@@ -405,7 +635,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report for existing organization and cluster ID",
-		Endpoint:               constructURLForReportForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusOK,
@@ -414,7 +645,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report for existing organization and non-existing cluster ID",
-		Endpoint:               constructURLForReportForOrgCluster(knownOrganizationID, unknownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": unknownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusNotFound,
@@ -423,7 +655,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report for non-existing organization and existing cluster ID",
-		Endpoint:               constructURLForReportForOrgCluster(unknownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": unknownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             true,
 		AuthHeaderOrganization: 100000,
@@ -433,7 +666,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report for non-existing organization and non-existing cluster ID",
-		Endpoint:               constructURLForReportForOrgCluster(unknownOrganizationID, unknownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": unknownOrganizationID, "cluster": unknownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             true,
 		AuthHeaderOrganization: 100000,
@@ -443,7 +677,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Reproducer for issue #384 (https://github.com/RedHatInsights/insights-results-aggregator/issues/384)",
-		Endpoint:               constructURLForReportForOrgCluster("000000000000000000000000000000000000", "1", testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": "000000000000000000000000000000000000", "cluster": "1", "user_id": string(testdata.UserID)},
 		Method:                 http.MethodOptions,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusBadRequest,
@@ -452,7 +687,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report for improper organization",
-		Endpoint:               constructURLForReportForOrgCluster(wrongOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": wrongOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusBadRequest,
@@ -461,7 +697,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report for existing organization and cluster ID w/o authorization token",
-		Endpoint:               constructURLForReportForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             false,
 		ExpectedStatus:         http.StatusUnauthorized,
@@ -470,7 +707,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report for existing organization and non-existing cluster ID w/o authorization token",
-		Endpoint:               constructURLForReportForOrgCluster(knownOrganizationID, unknownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": unknownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             false,
 		ExpectedStatus:         http.StatusUnauthorized,
@@ -479,7 +717,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report for non-existing organization and existing cluster ID w/o authorization token",
-		Endpoint:               constructURLForReportForOrgCluster(unknownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": unknownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             false,
 		ExpectedStatus:         http.StatusUnauthorized,
@@ -488,7 +727,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report for non-existing organization and non-existing cluster ID w/o authorization token",
-		Endpoint:               constructURLForReportForOrgCluster(unknownOrganizationID, unknownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": unknownOrganizationID, "cluster": unknownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             false,
 		ExpectedStatus:         http.StatusUnauthorized,
@@ -497,7 +737,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report for improper organization and known cluster w/o authorization token",
-		Endpoint:               constructURLForReportForOrgCluster(wrongOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": wrongOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             false,
 		ExpectedStatus:         http.StatusUnauthorized,
@@ -506,7 +747,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report for improper organization and unknown cluster w/o authorization token",
-		Endpoint:               constructURLForReportForOrgCluster(wrongOrganizationID, unknownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": wrongOrganizationID, "cluster": unknownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             false,
 		ExpectedStatus:         http.StatusUnauthorized,
@@ -515,7 +757,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve reports using wrong HTTP method POST",
-		Endpoint:               constructURLForReportForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodPost,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusMethodNotAllowed,
@@ -524,7 +767,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve reports using wrong HTTP method PUT",
-		Endpoint:               constructURLForReportForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodPut,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusMethodNotAllowed,
@@ -533,7 +777,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve reports using wrong HTTP method DELETE",
-		Endpoint:               constructURLForReportForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodDelete,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusMethodNotAllowed,
@@ -542,7 +787,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve reports using wrong HTTP method PATCH",
-		Endpoint:               constructURLForReportForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodPatch,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusMethodNotAllowed,
@@ -551,7 +797,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve reports using wrong HTTP method HEAD",
-		Endpoint:               constructURLForReportForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodHead,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusMethodNotAllowed,
@@ -560,7 +807,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve reports using correct HTTP method OPTIONS",
-		Endpoint:               constructURLForReportForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodOptions,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusOK,
@@ -569,7 +817,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata for existing organization and cluster ID",
-		Endpoint:               constructURLForReportInfoForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusOK,
@@ -578,7 +827,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata for existing organization and non-existing cluster ID",
-		Endpoint:               constructURLForReportInfoForOrgCluster(knownOrganizationID, unknownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": unknownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusNotFound,
@@ -587,7 +837,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata for unknown organization and cluster ID",
-		Endpoint:               constructURLForReportInfoForOrgCluster(unknownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": unknownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusNotFound,
@@ -596,7 +847,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata for unknown organization and non-existing cluster ID",
-		Endpoint:               constructURLForReportInfoForOrgCluster(unknownOrganizationID, unknownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": unknownOrganizationID, "cluster": unknownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusNotFound,
@@ -605,7 +857,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata for improper organization and known cluster ID",
-		Endpoint:               constructURLForReportInfoForOrgCluster(wrongOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": wrongOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusBadRequest,
@@ -614,7 +867,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata for improper organization and unknown cluster ID",
-		Endpoint:               constructURLForReportInfoForOrgCluster(wrongOrganizationID, unknownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": wrongOrganizationID, "cluster": unknownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusBadRequest,
@@ -623,7 +877,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata for existing organization and cluster ID w/o authorization token",
-		Endpoint:               constructURLForReportInfoForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             false,
 		ExpectedStatus:         http.StatusUnauthorized,
@@ -632,7 +887,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata for existing organization and unknown cluster ID w/o authorization token",
-		Endpoint:               constructURLForReportInfoForOrgCluster(knownOrganizationID, unknownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": unknownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             false,
 		ExpectedStatus:         http.StatusUnauthorized,
@@ -641,7 +897,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata for unknown organization and cluster ID w/o authorization token",
-		Endpoint:               constructURLForReportInfoForOrgCluster(unknownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": unknownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             false,
 		ExpectedStatus:         http.StatusUnauthorized,
@@ -650,7 +907,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata for unknown organization and unknown cluster ID w/o authorization token",
-		Endpoint:               constructURLForReportInfoForOrgCluster(unknownOrganizationID, unknownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": unknownOrganizationID, "cluster": unknownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             false,
 		ExpectedStatus:         http.StatusUnauthorized,
@@ -659,7 +917,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata for improper organization and cluster ID w/o authorization token",
-		Endpoint:               constructURLForReportInfoForOrgCluster(wrongOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": wrongOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             false,
 		ExpectedStatus:         http.StatusUnauthorized,
@@ -668,7 +927,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata for improper organization and unknown cluster ID w/o authorization token",
-		Endpoint:               constructURLForReportInfoForOrgCluster(wrongOrganizationID, unknownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": wrongOrganizationID, "cluster": unknownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodGet,
 		AuthHeader:             false,
 		ExpectedStatus:         http.StatusUnauthorized,
@@ -677,7 +937,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata using wrong HTTP method POST",
-		Endpoint:               constructURLForReportInfoForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodPost,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusMethodNotAllowed,
@@ -686,7 +947,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata using wrong HTTP method PUT",
-		Endpoint:               constructURLForReportInfoForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodPut,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusMethodNotAllowed,
@@ -695,7 +957,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata using wrong HTTP method DELETE",
-		Endpoint:               constructURLForReportInfoForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodDelete,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusMethodNotAllowed,
@@ -704,7 +967,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata using wrong HTTP method PATCH",
-		Endpoint:               constructURLForReportInfoForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodPatch,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusMethodNotAllowed,
@@ -713,7 +977,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata using wrong HTTP method HEAD",
-		Endpoint:               constructURLForReportInfoForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodHead,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusMethodNotAllowed,
@@ -722,7 +987,8 @@ var tests []RestAPITest = []RestAPITest{
 	},
 	{
 		Message:                "Check the endpoint to retrieve report metadata using correct HTTP method OPTIONS",
-		Endpoint:               constructURLForReportInfoForOrgCluster(knownOrganizationID, knownClusterForOrganization1, testdata.UserID),
+		Endpoint:               server.ReportMetainfoEndpoint,
+		PathParams:             map[string]string{"org_id": knownOrganizationID, "cluster": knownClusterForOrganization1, "user_id": string(testdata.UserID)},
 		Method:                 http.MethodOptions,
 		AuthHeader:             true,
 		ExpectedStatus:         http.StatusOK,
@@ -732,5 +998,57 @@ var tests []RestAPITest = []RestAPITest{
 }
 
 func main() {
-	os.Exit(runAllTests(tests))
+	flag.Parse()
+	apiURL = normalizeBaseURL(apiURL)
+
+	switch flag.Arg(0) {
+	case "", "run":
+		tests, err := selectedTests()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		err = setupTargetTransport()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		err = setupErrorSchema()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if *seedManifestFile != "" {
+			err = seedFromManifest(*seedManifestFile)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		os.Exit(runWithSUT(func() int {
+			err := checkTargetReady()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return exitCodeNotReady
+			}
+			return runAllTests(tests)
+		}))
+	case "har-import":
+		os.Exit(harImportCommand(flag.Args()[1:]))
+	case "lint":
+		os.Exit(lintCommand(flag.Args()[1:]))
+	case "capture":
+		os.Exit(captureCommand(flag.Args()[1:]))
+	case "compare":
+		os.Exit(compareCommand(flag.Args()[1:]))
+	case "contracts":
+		os.Exit(contractsCommand(flag.Args()[1:]))
+	case "seed":
+		os.Exit(seedCommand(flag.Args()[1:]))
+	case "history":
+		os.Exit(historyCommand(flag.Args()[1:]))
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", flag.Arg(0))
+		os.Exit(2)
+	}
 }
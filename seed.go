@@ -0,0 +1,193 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+	"github.com/rs/zerolog"
+)
+
+// seedManifestFile contains name of manifest with fixtures loaded into
+// database before tests
+var seedManifestFile = flag.String("seed", "",
+	"JSON manifest with fixtures to be loaded into database used by service under test before the run")
+
+// reportFixture represents report from insights-results-aggregator-data
+// together with rules parsed from it
+type reportFixture struct {
+	Report types.ClusterReport
+	Rules  []types.ReportItem
+}
+
+// reports from testdata package that can be referenced by manifest
+var reportFixtures = map[string]reportFixture{
+	"ReportEmpty": {
+		Report: testdata.ClusterReportEmpty,
+		Rules:  testdata.ReportEmptyRulesParsed,
+	},
+	"Report0Rules": {
+		Report: testdata.Report0Rules,
+		Rules:  testdata.ReportEmptyRulesParsed,
+	},
+	"Report2Rules": {
+		Report: testdata.Report2Rules,
+		Rules:  testdata.Report2RulesParsed,
+	},
+	"Report3Rules": {
+		Report: testdata.Report3Rules,
+		Rules:  testdata.Report3RulesParsed,
+	},
+}
+
+// SeedManifest describes database used by service under test and data the
+// tests rely on
+type SeedManifest struct {
+	// Storage has the same fields as storage configuration of
+	// insights-results-aggregator
+	Storage storage.Configuration
+
+	// Reports are written into database, AbsentClusters are clusters whose
+	// reports are deleted, because tests expect them to be unknown
+	Reports        []SeedReport
+	AbsentClusters []types.ClusterName
+}
+
+// SeedReport represents one report to be written into database. Report is
+// name of report from insights-results-aggregator-data.
+type SeedReport struct {
+	Description string
+	OrgID       types.OrgID
+	ClusterName types.ClusterName
+	Report      string
+}
+
+// knownFixtureNames returns sorted names of reports that can be used in
+// manifest
+func knownFixtureNames() []string {
+	names := make([]string, 0, len(reportFixtures))
+	for name := range reportFixtures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readSeedManifest reads manifest from JSON file and checks that all
+// referenced fixtures exist
+func readSeedManifest(filename string) (SeedManifest, error) {
+	var manifest SeedManifest
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return manifest, err
+	}
+
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		return manifest, err
+	}
+
+	for _, report := range manifest.Reports {
+		if _, found := reportFixtures[report.Report]; !found {
+			return manifest, fmt.Errorf("%s: unknown report %q, use one of %v",
+				filename, report.Report, knownFixtureNames())
+		}
+	}
+	return manifest, nil
+}
+
+// seedDatabase loads all fixtures from manifest into database. Database
+// schema is migrated to the latest version first.
+func seedDatabase(manifest SeedManifest) error {
+	// storage logs every statement, only problems are interesting here
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	db, err := storage.New(manifest.Storage)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	err = db.MigrateToLatest()
+	if err != nil {
+		return err
+	}
+
+	for _, cluster := range manifest.AbsentClusters {
+		err = db.DeleteReportsForCluster(cluster)
+		if err != nil {
+			return fmt.Errorf("unable to delete reports for cluster %s: %v", cluster, err)
+		}
+	}
+
+	now := time.Now()
+	for _, report := range manifest.Reports {
+		// older report would be kept otherwise
+		err = db.DeleteReportsForCluster(report.ClusterName)
+		if err != nil {
+			return fmt.Errorf("unable to delete reports for cluster %s: %v", report.ClusterName, err)
+		}
+
+		fixture := reportFixtures[report.Report]
+		err = db.WriteReportForCluster(report.OrgID, report.ClusterName, fixture.Report, fixture.Rules,
+			now, now, testdata.KafkaOffset)
+		if err != nil {
+			return fmt.Errorf("unable to write report %s for %d/%s: %v",
+				report.Report, report.OrgID, report.ClusterName, err)
+		}
+		fmt.Printf("Seeded %s for %d/%s: %s\n", report.Report, report.OrgID, report.ClusterName, report.Description)
+	}
+	return nil
+}
+
+// seedFromManifest loads fixtures described by manifest file into database
+func seedFromManifest(filename string) error {
+	manifest, err := readSeedManifest(filename)
+	if err != nil {
+		return err
+	}
+	return seedDatabase(manifest)
+}
+
+// seedCommand implements the seed command that loads fixtures into database
+// without running tests
+func seedCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: seed manifest.json")
+		return 2
+	}
+
+	err := seedFromManifest(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,323 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/verdverm/frisby"
+)
+
+// default settings of service under test started by the runner
+const (
+	defaultSUTLogFile          = "sut.log"
+	defaultSUTReadinessTimeout = time.Minute
+	defaultSUTStopTimeout      = 10 * time.Second
+)
+
+// time to wait for log lines written by service under test after the
+// response was received
+const sutLogGrace = 100 * time.Millisecond
+
+// sutConfigFile contains name of file with configuration of service under
+// test that is started before tests
+var sutConfigFile = flag.String("sut", "",
+	"JSON file with configuration of service under test to be started before and stopped after the run")
+
+// SUTConfig represents configuration of service under test started as local
+// process
+type SUTConfig struct {
+	// Command is name of executable followed by its arguments
+	Command    []string
+	Env        map[string]string
+	WorkingDir string
+
+	// ReadinessURL is polled until the service responds, apiURL is used
+	// when it is not set
+	ReadinessURL     string
+	ReadinessTimeout Duration
+	StopTimeout      Duration
+
+	// LogFile is file where standard and error outputs of the service are
+	// written into
+	LogFile string
+}
+
+// sutLogLine represents one line written by service under test
+type sutLogLine struct {
+	Time   time.Time
+	Stream string
+	Text   string
+}
+
+// sutLog collects lines written by service under test and stores them into
+// log file
+type sutLog struct {
+	mutex sync.Mutex
+	file  *os.File
+	lines []sutLogLine
+}
+
+// add stores one line written by service under test
+func (l *sutLog) add(stream string, text string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	line := sutLogLine{
+		Time:   time.Now(),
+		Stream: stream,
+		Text:   text,
+	}
+	l.lines = append(l.lines, line)
+	fmt.Fprintf(l.file, "%s [%s] %s\n", line.Time.Format(time.RFC3339Nano), stream, text)
+}
+
+// linesBetween returns all lines written in given time interval
+func (l *sutLog) linesBetween(from time.Time, to time.Time) []sutLogLine {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var lines []sutLogLine
+	for _, line := range l.lines {
+		if !line.Time.Before(from) && !line.Time.After(to) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// sutLogWriter splits output of service under test into lines
+type sutLogWriter struct {
+	log     *sutLog
+	stream  string
+	partial []byte
+}
+
+// Write implements io.Writer interface
+func (w *sutLogWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.log.add(w.stream, string(bytes.TrimRight(w.partial[:i], "\r")))
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush writes the last line that is not terminated by newline
+func (w *sutLogWriter) flush() {
+	if len(w.partial) != 0 {
+		w.log.add(w.stream, string(bytes.TrimRight(w.partial, "\r")))
+		w.partial = nil
+	}
+}
+
+// sutProcess represents running service under test
+type sutProcess struct {
+	config SUTConfig
+	cmd    *exec.Cmd
+	log    *sutLog
+
+	// exited is closed when process exits, exit error is stored then
+	exited  chan struct{}
+	exitErr error
+}
+
+// service under test started for current run, nil when the service is not
+// managed by the runner
+var runningSUT *sutProcess
+
+// readSUTConfig reads configuration of service under test from JSON file and
+// fills in default values
+func readSUTConfig(filename string) (SUTConfig, error) {
+	var config SUTConfig
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		return config, err
+	}
+	if len(config.Command) == 0 {
+		return config, fmt.Errorf("%s: command is not specified", filename)
+	}
+
+	if config.ReadinessURL == "" {
+		config.ReadinessURL = apiURL
+	}
+	if config.ReadinessTimeout == 0 {
+		config.ReadinessTimeout = Duration(defaultSUTReadinessTimeout)
+	}
+	if config.StopTimeout == 0 {
+		config.StopTimeout = Duration(defaultSUTStopTimeout)
+	}
+	if config.LogFile == "" {
+		config.LogFile = defaultSUTLogFile
+	}
+	return config, nil
+}
+
+// startSUT starts service under test and waits until it is ready
+func startSUT(config SUTConfig) (*sutProcess, error) {
+	file, err := os.Create(config.LogFile)
+	if err != nil {
+		return nil, err
+	}
+
+	log := &sutLog{file: file}
+
+	cmd := exec.Command(config.Command[0], config.Command[1:]...)
+	cmd.Dir = config.WorkingDir
+	cmd.Env = os.Environ()
+	for name, value := range config.Env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	stdout := &sutLogWriter{log: log, stream: "stdout"}
+	stderr := &sutLogWriter{log: log, stream: "stderr"}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Start()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	sut := &sutProcess{
+		config: config,
+		cmd:    cmd,
+		log:    log,
+		exited: make(chan struct{}),
+	}
+	go func() {
+		sut.exitErr = cmd.Wait()
+		// outputs are completely copied when Wait returns
+		stdout.flush()
+		stderr.flush()
+		close(sut.exited)
+	}()
+
+	err = waitUntilReady(config.ReadinessURL, time.Duration(config.ReadinessTimeout), sut.exited)
+	if err != nil {
+		select {
+		case <-sut.exited:
+			if sut.exitErr != nil {
+				err = fmt.Errorf("%v: %v", err, sut.exitErr)
+			}
+		default:
+		}
+		sut.stop()
+		return nil, &notReadyError{
+			URL: config.ReadinessURL,
+			Err: fmt.Errorf("%v, see %s", err, config.LogFile),
+		}
+	}
+	return sut, nil
+}
+
+// stop stops service under test, it is killed when it doesn't exit in time
+func (s *sutProcess) stop() {
+	if s == nil {
+		return
+	}
+
+	// signal is not supported on all platforms, process is killed then
+	err := s.cmd.Process.Signal(os.Interrupt)
+	if err != nil {
+		_ = s.cmd.Process.Kill()
+	}
+
+	select {
+	case <-s.exited:
+	case <-time.After(time.Duration(s.config.StopTimeout)):
+		_ = s.cmd.Process.Kill()
+		<-s.exited
+	}
+	_ = s.log.file.Close()
+}
+
+// sutLogWanted checks if lines written by service under test need to be
+// printed, they are printed for failed tests only
+func sutLogWanted(f *frisby.Frisby, e *exchange) bool {
+	return runningSUT != nil && len(f.Errs) != 0 && e.Request != nil
+}
+
+// waitForSUTLog gives service under test time to write log of failed test,
+// other tests can continue in the meantime
+func waitForSUTLog(f *frisby.Frisby, e *exchange) {
+	if sutLogWanted(f, e) {
+		sleepUnlocked(sutLogGrace)
+	}
+}
+
+// printSUTLog prints lines written by service under test while failed test
+// was performed
+func printSUTLog(f *frisby.Frisby, e *exchange) {
+	if !sutLogWanted(f, e) {
+		return
+	}
+
+	lines := runningSUT.log.linesBetween(e.Started, time.Now())
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Printf("        service log (%s):\n", runningSUT.config.LogFile)
+	for _, line := range lines {
+		fmt.Printf("          %s [%s] %s\n", line.Time.Format("15:04:05.000"), line.Stream, line.Text)
+	}
+}
+
+// runWithSUT starts service under test when it is configured, performs given
+// function and stops the service
+func runWithSUT(run func() int) int {
+	if *sutConfigFile == "" {
+		return run()
+	}
+
+	config, err := readSUTConfig(*sutConfigFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	runningSUT, err = startSUT(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if _, ok := err.(*notReadyError); ok {
+			return exitCodeNotReady
+		}
+		return 1
+	}
+	defer runningSUT.stop()
+
+	return run()
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSUTLogWriter(t *testing.T) {
+	file, err := ioutil.TempFile("", "sut-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	log := &sutLog{file: file}
+	w := &sutLogWriter{log: log, stream: "stderr"}
+
+	// lines can be split between writes, the last one is not terminated
+	for _, chunk := range []string{"start", "ed\r\nrequest ", "received\npanic: ", "nil map"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.flush()
+	w.flush()
+
+	var lines []string
+	for _, line := range log.lines {
+		if line.Stream != "stderr" {
+			t.Errorf("unexpected stream %q", line.Stream)
+		}
+		lines = append(lines, line.Text)
+	}
+	expected := []string{"started", "request received", "panic: nil map"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected lines %q, got %q", expected, lines)
+	}
+}
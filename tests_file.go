@@ -0,0 +1,75 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+)
+
+// testsFile contains name of JSON file with tests that are used instead of
+// the built-in table
+var testsFile = flag.String("tests", "",
+	"read tests from given JSON file instead of using the built-in table")
+
+// readTestsFile reads table with tests from JSON file
+func readTestsFile(filename string) ([]RestAPITest, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var tests []RestAPITest
+	err = json.Unmarshal(content, &tests)
+	if err != nil {
+		return nil, err
+	}
+	return tests, nil
+}
+
+// writeTestsFile writes table with tests into JSON file or to standard
+// output when filename is not specified
+func writeTestsFile(filename string, tests []RestAPITest) error {
+	// endpoints often contain characters like & that should stay readable
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+
+	err := encoder.Encode(tests)
+	if err != nil {
+		return err
+	}
+
+	if filename == "" {
+		_, err = os.Stdout.Write(content.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(filename, content.Bytes(), 0644)
+}
+
+// selectedTests returns tests read from file when specified on command line
+// or the built-in table
+func selectedTests() ([]RestAPITest, error) {
+	if *testsFile == "" {
+		return tests, nil
+	}
+	return readTestsFile(*testsFile)
+}
@@ -0,0 +1,217 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/verdverm/frisby"
+)
+
+// slowTestThreshold contains duration after which warning about slow test
+// is printed
+var slowTestThreshold = flag.Duration("slow-threshold", 0,
+	"print warning for every test that takes longer than given duration (e.g. 500ms)")
+
+// Duration is duration that is written as string, like "250ms", in JSON
+// files. Integer number of nanoseconds is accepted too.
+type Duration time.Duration
+
+// String returns duration in the same format as time.Duration
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON writes duration as string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads duration from string or from number of nanoseconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(text, `"`) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		duration, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(duration)
+		return nil
+	}
+
+	nanoseconds, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("duration needs to be string like \"250ms\", but got %s", text)
+	}
+	*d = Duration(nanoseconds)
+	return nil
+}
+
+// requestTiming represents time spent in individual phases of request. DNS,
+// Connect and TLS are zero when already opened connection was reused.
+type requestTiming struct {
+	DNS              time.Duration
+	Connect          time.Duration
+	TLS              time.Duration
+	TimeToFirstByte  time.Duration
+	Total            time.Duration
+	ConnectionReused bool
+}
+
+// String returns timing breakdown in format suitable for printing to
+// terminal
+func (t requestTiming) String() string {
+	reused := ""
+	if t.ConnectionReused {
+		reused = " (connection reused)"
+	}
+	return fmt.Sprintf("dns %v, connect %v, tls %v, ttfb %v, total %v%s",
+		t.DNS, t.Connect, t.TLS, t.TimeToFirstByte, t.Total, reused)
+}
+
+// tracingTransport is HTTP transport that measures time spent in individual
+// phases of request using httptrace. It records the request as sent to the
+// server, including cookies added by cookie jar.
+type tracingTransport struct {
+	Base     http.RoundTripper
+	Exchange *exchange
+	start    time.Time
+	recorded bool
+}
+
+// RoundTrip performs HTTP request with attached client trace. Response body
+// is read before RoundTrip returns, so the total time does not include time
+// spent by waiting for other tests performed concurrently.
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var dnsStart, connectStart, tlsStart time.Time
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.Exchange.Timing.DNS = time.Since(dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			t.Exchange.Timing.Connect = time.Since(connectStart)
+		},
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.Exchange.Timing.TLS = time.Since(tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.Exchange.Timing.ConnectionReused = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			t.Exchange.Timing.TimeToFirstByte = time.Since(t.start)
+		},
+	}
+
+	// the first request is recorded, redirects are followed by client and
+	// they are included in time to first byte and total time
+	if !t.recorded {
+		t.Exchange.Request = req
+		t.recorded = true
+		t.start = time.Now()
+	}
+
+	resp, err := t.Base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err == nil {
+		bufferBody(resp)
+	}
+	t.Exchange.Timing.Total = time.Since(t.start)
+	return resp, err
+}
+
+// bufferBody reads the whole response body into memory, error found while
+// reading is returned when the buffered body is read
+func bufferBody(resp *http.Response) {
+	raw, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		resp.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(raw), failingReader{err}))
+		return
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(raw))
+}
+
+// traceRequest wraps transport used by Frisby object to record the request
+// and to measure timing breakdown of the request
+func traceRequest(f *frisby.Frisby, e *exchange) {
+	base := f.Req.Client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	f.Req.Client.Transport = &tracingTransport{Base: base, Exchange: e}
+}
+
+// checkDuration checks that the request was not slower than expected by
+// test, warnings for slow tests are printed by printTiming
+func checkDuration(f *frisby.Frisby, test *RestAPITest, timing requestTiming) {
+	if test.ExpectedMaxDuration != 0 && timing.Total > time.Duration(test.ExpectedMaxDuration) {
+		f.AddError(fmt.Sprintf("Expected request to take at most %v, but it took %v",
+			test.ExpectedMaxDuration, timing.Total))
+	}
+}
+
+// printTiming prints timing breakdown of the request together with warning
+// when the request was slower than global threshold
+func printTiming(f *frisby.Frisby, timing requestTiming) {
+	fmt.Println("        timing: " + timing.String())
+	if *slowTestThreshold != 0 && timing.Total > *slowTestThreshold {
+		fmt.Printf("WARN  [%s] took %v, slow test threshold is %v\n",
+			f.Name, timing.Total, *slowTestThreshold)
+	}
+}
+
+// millisecondsOrNone converts duration into milliseconds as used in HAR
+// files, -1 is returned for phases that does not apply
+func millisecondsOrNone(d time.Duration) float64 {
+	if d == 0 {
+		return -1
+	}
+	return milliseconds(d)
+}
+
+// milliseconds converts duration into milliseconds with fraction
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/verdverm/frisby"
+)
+
+func TestDurationUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Duration
+		valid    bool
+	}{
+		{`"250ms"`, Duration(250 * time.Millisecond), true},
+		{`"1m30s"`, Duration(90 * time.Second), true},
+		{`1000000`, Duration(time.Millisecond), true},
+		{`null`, 0, true},
+		{`"250"`, 0, false},
+		{`"fast"`, 0, false},
+		{`1.5`, 0, false},
+		{`true`, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var d Duration
+			err := json.Unmarshal([]byte(tt.input), &d)
+			if tt.valid && (err != nil || d != tt.expected) {
+				t.Errorf("expected %v, got %v (error %v)", tt.expected, d, err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("expected error, got %v", d)
+			}
+		})
+	}
+}
+
+func TestDurationMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(ExpectedCookie{Name: "session", MinLifetime: Duration(250 * time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Name":"session","MinLifetime":"250ms"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+}
+
+func TestTimingIncludesRedirects(t *testing.T) {
+	const delay = 50 * time.Millisecond
+
+	// only the first hop is slow
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			time.Sleep(delay)
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	f := frisby.Create("redirect").Get(server.URL + "/old")
+	e := &exchange{}
+	traceRequest(f, e)
+	f.Send()
+	if f.Resp == nil || f.Resp.Response == nil {
+		t.Fatalf("no response: %v", f.Error())
+	}
+
+	if e.Request == nil || e.Request.URL.Path != "/old" {
+		t.Errorf("expected the first request to be recorded, got %v", e.Request)
+	}
+	if e.Timing.TimeToFirstByte < delay || e.Timing.Total < delay {
+		t.Errorf("expected timing to include redirect taking %v, got %s", delay, e.Timing)
+	}
+}
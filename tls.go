@@ -0,0 +1,214 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/verdverm/frisby"
+)
+
+// names of TLS versions used by ExpectedTLSVersion
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS1.0",
+	tls.VersionTLS11: "TLS1.1",
+	tls.VersionTLS12: "TLS1.2",
+	tls.VersionTLS13: "TLS1.3",
+}
+
+// tlsOptions represents TLS configuration used to connect to the target
+type tlsOptions struct {
+	CACertFile         string
+	ClientCertFile     string
+	ClientKeyFile      string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// TLS configuration read from command line
+var targetTLSOptions tlsOptions
+
+func init() {
+	flag.StringVar(&targetTLSOptions.CACertFile, "ca-cert", "",
+		"PEM file with CA certificates used to verify HTTPS target")
+	flag.StringVar(&targetTLSOptions.ClientCertFile, "client-cert", "",
+		"PEM file with client certificate used for mutual TLS")
+	flag.StringVar(&targetTLSOptions.ClientKeyFile, "client-key", "",
+		"PEM file with private key of client certificate used for mutual TLS")
+	flag.StringVar(&targetTLSOptions.ServerName, "sni", "",
+		"server name sent in TLS handshake and used to verify certificate of HTTPS target")
+	flag.BoolVar(&targetTLSOptions.InsecureSkipVerify, "insecure", false,
+		"don't verify certificate of HTTPS target")
+}
+
+// transport shared by all tests, it is prepared by setupTargetTransport
+var targetTransport *http.Transport
+
+// newTLSConfig constructs TLS configuration from given options
+func newTLSConfig(options tlsOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         options.ServerName,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+
+	if options.CACertFile != "" {
+		pem, err := ioutil.ReadFile(options.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", options.CACertFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if options.ClientCertFile != "" || options.ClientKeyFile != "" {
+		if options.ClientCertFile == "" || options.ClientKeyFile == "" {
+			return nil, errors.New("both client certificate and client key need to be specified")
+		}
+		certificate, err := tls.LoadX509KeyPair(options.ClientCertFile, options.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+// newTargetTransport constructs HTTP transport that connects to the target
+// with given TLS options
+func newTargetTransport(options tlsOptions) (*http.Transport, error) {
+	config, err := newTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return transport, nil
+}
+
+// setupTargetTransport prepares transport shared by all tests, it needs to be
+// called after command line flags are parsed
+func setupTargetTransport() error {
+	transport, err := newTargetTransport(targetTLSOptions)
+	if err != nil {
+		return err
+	}
+	err = configureProxy(transport, *proxyURL)
+	if err != nil {
+		return err
+	}
+	targetTransport = transport
+	return nil
+}
+
+// useTargetTransport sets transport configured for the target to Frisby
+// object
+func useTargetTransport(f *frisby.Frisby) {
+	if targetTransport != nil {
+		f.Req.Client.Transport = targetTransport
+	}
+}
+
+// checkTLS checks negotiated TLS version, cipher suite and subject of peer
+// certificate
+func checkTLS(f *frisby.Frisby, test *RestAPITest) {
+	if test.ExpectedTLSVersion == "" && test.ExpectedCipherSuite == "" && test.ExpectedPeerSubject == "" {
+		return
+	}
+
+	state := f.Resp.TLS
+	if state == nil {
+		f.AddError("Expected TLS connection, but plain HTTP was used")
+		return
+	}
+
+	if test.ExpectedTLSVersion != "" && tlsVersions[state.Version] != test.ExpectedTLSVersion {
+		f.AddError(fmt.Sprintf("Expected TLS version %s, but got %s",
+			test.ExpectedTLSVersion, tlsVersions[state.Version]))
+	}
+
+	cipherSuite := tls.CipherSuiteName(state.CipherSuite)
+	if test.ExpectedCipherSuite != "" && cipherSuite != test.ExpectedCipherSuite {
+		f.AddError(fmt.Sprintf("Expected cipher suite %s, but got %s", test.ExpectedCipherSuite, cipherSuite))
+	}
+
+	if test.ExpectedPeerSubject != "" {
+		if len(state.PeerCertificates) == 0 {
+			f.AddError("Expected peer certificate, but none was sent")
+			return
+		}
+		subject := state.PeerCertificates[0].Subject
+
+		// exact value can be either common name or the whole subject
+		matched := test.ExpectedPeerSubject == subject.CommonName
+		if !matched {
+			ok, err := matchHeaderValue(test.ExpectedPeerSubject, subject.String())
+			if err != nil {
+				f.AddError(fmt.Sprintf("Improper matcher for peer certificate subject: %v", err))
+				return
+			}
+			matched = ok
+		}
+		if !matched {
+			f.AddError(fmt.Sprintf("Expected peer certificate subject to have %s, but got %q",
+				describeHeaderMatcher(test.ExpectedPeerSubject), subject.String()))
+		}
+	}
+}
+
+// lintTLS checks that expected TLS parameters are known
+func lintTLS(test *RestAPITest) []string {
+	var problems []string
+
+	if test.ExpectedTLSVersion != "" {
+		known := false
+		for _, version := range tlsVersions {
+			known = known || version == test.ExpectedTLSVersion
+		}
+		if !known {
+			problems = append(problems, fmt.Sprintf("unknown TLS version %q", test.ExpectedTLSVersion))
+		}
+	}
+
+	if test.ExpectedCipherSuite != "" {
+		known := false
+		for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+			known = known || suite.Name == test.ExpectedCipherSuite
+		}
+		if !known {
+			problems = append(problems, fmt.Sprintf("unknown cipher suite %q", test.ExpectedCipherSuite))
+		}
+	}
+
+	if strings.HasPrefix(apiURL, "http:") &&
+		(test.ExpectedTLSVersion != "" || test.ExpectedCipherSuite != "" || test.ExpectedPeerSubject != "") {
+		problems = append(problems, fmt.Sprintf("TLS parameters are expected, but %s is not HTTPS URL", apiURL))
+	}
+
+	return problems
+}
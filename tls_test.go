@@ -0,0 +1,299 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/verdverm/frisby"
+)
+
+// newTestTLSServer starts local HTTPS server with given TLS configuration,
+// certificate generated by httptest is used when none is set
+func newTestTLSServer(t *testing.T, config *tls.Config) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = config
+	// handshake errors are expected by some tests
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeTempFile writes PEM blocks into file in temporary directory
+func writeTempFile(t *testing.T, name string, blocks ...*pem.Block) string {
+	dir, err := ioutil.TempDir("", "tls-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	var content []byte
+	for _, block := range blocks {
+		content = append(content, pem.EncodeToMemory(block)...)
+	}
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// writeCertificate writes certificate of test server into PEM file
+func writeCertificate(t *testing.T, certificate *x509.Certificate) string {
+	return writeTempFile(t, "ca.pem", &pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+}
+
+// selfSignedCertificate generates self-signed certificate for localhost that
+// is valid in given time range
+func selfSignedCertificate(t *testing.T, notBefore time.Time, notAfter time.Time) (tls.Certificate, *pem.Block, *pem.Block) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost", Organization: []string{"Test"}},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certBlock := &pem.Block{Type: "CERTIFICATE", Bytes: der}
+	keyBlock := &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}
+	certificate, err := tls.X509KeyPair(pem.EncodeToMemory(certBlock), pem.EncodeToMemory(keyBlock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate, certBlock, keyBlock
+}
+
+// get performs GET request using transport constructed from given options
+func get(t *testing.T, options tlsOptions, url string) error {
+	transport, err := newTargetTransport(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+
+	resp, err := (&http.Client{Transport: transport}).Get(url)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	notPEM := writeTempFile(t, "empty.pem")
+
+	tests := []struct {
+		name    string
+		options tlsOptions
+		problem string
+	}{
+		{"missing CA file", tlsOptions{CACertFile: "does-not-exist.pem"}, "no such file"},
+		{"CA file without certificates", tlsOptions{CACertFile: notPEM}, "no certificates found"},
+		{"client certificate without key", tlsOptions{ClientCertFile: "cert.pem"}, "both client certificate and client key"},
+		{"client key without certificate", tlsOptions{ClientKeyFile: "key.pem"}, "both client certificate and client key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTLSConfig(tt.options)
+			if err == nil || !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("expected error containing %q, got %v", tt.problem, err)
+			}
+		})
+	}
+}
+
+func TestTargetCertificateVerification(t *testing.T) {
+	server := newTestTLSServer(t, nil)
+	caFile := writeCertificate(t, server.Certificate())
+
+	now := time.Now()
+	expired, expiredCert, _ := selfSignedCertificate(t, now.Add(-2*time.Hour), now.Add(-time.Hour))
+	expiredServer := newTestTLSServer(t, &tls.Config{Certificates: []tls.Certificate{expired}})
+	expiredCAFile := writeTempFile(t, "expired.pem", expiredCert)
+
+	tests := []struct {
+		name    string
+		url     string
+		options tlsOptions
+		problem string
+	}{
+		{"CA bundle", server.URL, tlsOptions{CACertFile: caFile}, ""},
+		{"unknown CA", server.URL, tlsOptions{}, "certificate"},
+		{"insecure", server.URL, tlsOptions{InsecureSkipVerify: true}, ""},
+		{"SNI not in certificate", server.URL, tlsOptions{CACertFile: caFile, ServerName: "unknown.test"}, "unknown.test"},
+		{"SNI in certificate", server.URL, tlsOptions{CACertFile: caFile, ServerName: "example.com"}, ""},
+		{"expired certificate", expiredServer.URL, tlsOptions{CACertFile: expiredCAFile}, "expired"},
+		{"expired certificate, insecure", expiredServer.URL, tlsOptions{InsecureSkipVerify: true}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := get(t, tt.options, tt.url)
+			switch {
+			case tt.problem == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.problem != "" && (err == nil || !strings.Contains(err.Error(), tt.problem)):
+				t.Errorf("expected error containing %q, got %v", tt.problem, err)
+			}
+		})
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	now := time.Now()
+	_, clientCert, clientKey := selfSignedCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))
+	clients := x509.NewCertPool()
+	clients.AppendCertsFromPEM(pem.EncodeToMemory(clientCert))
+
+	server := newTestTLSServer(t, &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clients})
+	caFile := writeCertificate(t, server.Certificate())
+	certFile := writeTempFile(t, "client.pem", clientCert)
+	keyFile := writeTempFile(t, "client-key.pem", clientKey)
+
+	err := get(t, tlsOptions{CACertFile: caFile, ClientCertFile: certFile, ClientKeyFile: keyFile}, server.URL)
+	if err != nil {
+		t.Errorf("unexpected error with client certificate: %v", err)
+	}
+
+	err = get(t, tlsOptions{CACertFile: caFile}, server.URL)
+	if err == nil {
+		t.Error("expected request without client certificate to be rejected")
+	}
+}
+
+func TestCheckTLS(t *testing.T) {
+	const cipherSuite = tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+	server := newTestTLSServer(t, &tls.Config{
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{cipherSuite},
+	})
+	transport, err := newTargetTransport(tlsOptions{CACertFile: writeCertificate(t, server.Certificate())})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		test     RestAPITest
+		problems []string
+	}{
+		{"no expectations", RestAPITest{}, nil},
+		{"version", RestAPITest{ExpectedTLSVersion: "TLS1.2"}, nil},
+		{"wrong version", RestAPITest{ExpectedTLSVersion: "TLS1.3"},
+			[]string{"Expected TLS version TLS1.3, but got TLS1.2"}},
+		{"cipher suite", RestAPITest{ExpectedCipherSuite: tls.CipherSuiteName(cipherSuite)}, nil},
+		{"wrong cipher suite", RestAPITest{ExpectedCipherSuite: "TLS_AES_128_GCM_SHA256"},
+			[]string{"Expected cipher suite TLS_AES_128_GCM_SHA256, but got TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+		{"peer subject regex", RestAPITest{ExpectedPeerSubject: HeaderRegexPrefix + "O=Acme Co"}, nil},
+		{"wrong peer subject", RestAPITest{ExpectedPeerSubject: "example.com"},
+			[]string{`Expected peer certificate subject to have value "example.com", but got "O=Acme Co"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := frisby.Create(tt.name).Get(server.URL)
+			f.Req.Client.Transport = transport
+			f.Send()
+			if f.Resp == nil || f.Resp.Response == nil {
+				t.Fatalf("no response: %v", f.Error())
+			}
+
+			checkTLS(f, &tt.test)
+
+			var problems []string
+			for _, err := range f.Errors() {
+				problems = append(problems, err.Error())
+			}
+			if strings.Join(problems, "\n") != strings.Join(tt.problems, "\n") {
+				t.Errorf("expected problems %q, got %q", tt.problems, problems)
+			}
+		})
+	}
+}
+
+func TestLintTLS(t *testing.T) {
+	savedURL := apiURL
+	defer func() {
+		apiURL = savedURL
+	}()
+
+	tests := []struct {
+		name     string
+		url      string
+		test     RestAPITest
+		problems []string
+	}{
+		{"known parameters", "https://localhost/", RestAPITest{ExpectedTLSVersion: "TLS1.3",
+			ExpectedCipherSuite: "TLS_AES_128_GCM_SHA256"}, nil},
+		{"unknown version", "https://localhost/", RestAPITest{ExpectedTLSVersion: "SSL3"},
+			[]string{`unknown TLS version "SSL3"`}},
+		{"unknown cipher suite", "https://localhost/", RestAPITest{ExpectedCipherSuite: "ROT13"},
+			[]string{`unknown cipher suite "ROT13"`}},
+		{"plain HTTP", "http://localhost/", RestAPITest{ExpectedPeerSubject: "localhost"},
+			[]string{"TLS parameters are expected, but http://localhost/ is not HTTPS URL"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiURL = tt.url
+			problems := lintTLS(&tt.test)
+			if strings.Join(problems, "\n") != strings.Join(tt.problems, "\n") {
+				t.Errorf("expected problems %q, got %q", tt.problems, problems)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// templateParamPattern matches parameter in endpoint template, like
+// {org_id}. The same syntax is used by endpoints of the aggregator.
+var templateParamPattern = regexp.MustCompile(`\{([a-zA-Z_0-9]+)\}`)
+
+// templateParams returns names of all parameters used in endpoint template
+func templateParams(endpoint string) []string {
+	var names []string
+	for _, match := range templateParamPattern.FindAllStringSubmatch(endpoint, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// templateProblems checks that all parameters used in endpoint template are
+// set and that all set parameters are used. Values . and .. are refused,
+// because they would be resolved as dot segments and change the endpoint.
+func templateProblems(test *RestAPITest) []string {
+	var problems []string
+
+	used := make(map[string]bool)
+	for _, name := range templateParams(test.Endpoint) {
+		if _, found := test.PathParams[name]; !found && !used[name] {
+			problems = append(problems, fmt.Sprintf("path parameter {%s} is not set in PathParams", name))
+		}
+		used[name] = true
+	}
+
+	for _, name := range sortedKeys(test.PathParams) {
+		if !used[name] {
+			problems = append(problems, fmt.Sprintf("path parameter %q is not used by Endpoint", name))
+		}
+		if value := test.PathParams[name]; value == "." || value == ".." {
+			problems = append(problems, fmt.Sprintf("path parameter %q can't have value %q", name, value))
+		}
+	}
+
+	if strings.ContainsAny(templateParamPattern.ReplaceAllString(test.Endpoint, ""), "{}") {
+		problems = append(problems, fmt.Sprintf("Endpoint %q contains improper path parameter", test.Endpoint))
+	}
+
+	if _, found := test.QueryParams[""]; found {
+		problems = append(problems, "QueryParams contains parameter without name")
+	}
+
+	return problems
+}
+
+// expandPath replaces parameters in endpoint template by escaped values from
+// PathParams
+func expandPath(test *RestAPITest) (string, error) {
+	problems := templateProblems(test)
+	if len(problems) != 0 {
+		return "", errors.New(problems[0])
+	}
+
+	return templateParamPattern.ReplaceAllStringFunc(test.Endpoint, func(param string) string {
+		return url.PathEscape(test.PathParams[strings.Trim(param, "{}")])
+	}), nil
+}
+
+// expandEndpoint returns endpoint with parameters from PathParams and with
+// query parameters from QueryParams. The result is relative to apiURL.
+func expandEndpoint(test *RestAPITest) (string, error) {
+	endpoint, err := expandPath(test)
+	if err != nil || len(test.QueryParams) == 0 {
+		return endpoint, err
+	}
+
+	query := url.Values{}
+	for name, value := range test.QueryParams {
+		query.Set(name, value)
+	}
+
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+	return endpoint + separator + query.Encode(), nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright © 2022 Pavel Tisnovsky
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		test     RestAPITest
+		expected string
+	}{
+		{"without parameters", RestAPITest{Endpoint: "organizations"}, "organizations"},
+		{"path parameters", RestAPITest{
+			Endpoint:   "report/{org}/{cluster}",
+			PathParams: map[string]string{"org": "1", "cluster": "34c3ecc5"},
+		}, "report/1/34c3ecc5"},
+		{"repeated path parameter", RestAPITest{
+			Endpoint:   "{id}/{id}",
+			PathParams: map[string]string{"id": "x"},
+		}, "x/x"},
+		{"escaped path parameter", RestAPITest{
+			Endpoint:   "rules/{rule}",
+			PathParams: map[string]string{"rule": "a/b c?d#e%"},
+		}, "rules/a%2Fb%20c%3Fd%23e%25"},
+		{"dots inside path parameter", RestAPITest{
+			Endpoint:   "rules/{rule}",
+			PathParams: map[string]string{"rule": "rule.module..key"},
+		}, "rules/rule.module..key"},
+		{"query parameters", RestAPITest{
+			Endpoint:    "clusters",
+			QueryParams: map[string]string{"b": "x y", "a": "1&2"},
+		}, "clusters?a=1%262&b=x+y"},
+		{"existing query string", RestAPITest{
+			Endpoint:    "clusters?limit=10",
+			QueryParams: map[string]string{"offset": "20"},
+		}, "clusters?limit=10&offset=20"},
+		{"path and query parameters", RestAPITest{
+			Endpoint:    "report/{org}",
+			PathParams:  map[string]string{"org": "1"},
+			QueryParams: map[string]string{"cluster": "c"},
+		}, "report/1?cluster=c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint, err := expandEndpoint(&tt.test)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if endpoint != tt.expected {
+				t.Errorf("expected endpoint %q, got %q", tt.expected, endpoint)
+			}
+		})
+	}
+}
+
+func TestTemplateProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		test     RestAPITest
+		problems []string
+	}{
+		{"proper template", RestAPITest{
+			Endpoint:   "report/{org}",
+			PathParams: map[string]string{"org": "1"},
+		}, nil},
+		{"missing parameter", RestAPITest{
+			Endpoint:   "report/{org}/{cluster}/{cluster}",
+			PathParams: map[string]string{"org": "1"},
+		}, []string{"path parameter {cluster} is not set in PathParams"}},
+		{"unused parameter", RestAPITest{
+			Endpoint:   "report/{org}",
+			PathParams: map[string]string{"org": "1", "cluster": "c"},
+		}, []string{`path parameter "cluster" is not used by Endpoint`}},
+		{"dot segment", RestAPITest{
+			Endpoint:   "report/{org}",
+			PathParams: map[string]string{"org": "."},
+		}, []string{`path parameter "org" can't have value "."`}},
+		{"double dot segment", RestAPITest{
+			Endpoint:   "report/{org}",
+			PathParams: map[string]string{"org": ".."},
+		}, []string{`path parameter "org" can't have value ".."`}},
+		{"improper parameter", RestAPITest{
+			Endpoint: "report/{org-id}",
+		}, []string{`Endpoint "report/{org-id}" contains improper path parameter`}},
+		{"query parameter without name", RestAPITest{
+			Endpoint:    "report",
+			QueryParams: map[string]string{"": "1"},
+		}, []string{"QueryParams contains parameter without name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := templateProblems(&tt.test)
+			if strings.Join(problems, "\n") != strings.Join(tt.problems, "\n") {
+				t.Errorf("expected problems %q, got %q", tt.problems, problems)
+			}
+
+			_, err := expandEndpoint(&tt.test)
+			if len(tt.problems) != 0 && (err == nil || err.Error() != tt.problems[0]) {
+				t.Errorf("expected error %q, got %v", tt.problems[0], err)
+			}
+		})
+	}
+}